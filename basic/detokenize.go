@@ -0,0 +1,190 @@
+package basic
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/blackchip-org/vt128/petscii"
+)
+
+var (
+	ErrBadLink   = fmt.Errorf("bad line link")
+	ErrTruncated = fmt.Errorf("program is truncated")
+)
+
+// Dialect is a version of BASIC which determines the keywords used for
+// each token.
+type Dialect int
+
+const (
+	Basic20 Dialect = iota // C64 and VIC-20
+	Basic35                // Plus/4 and C16
+	Basic70                // C128
+)
+
+var dialectStr = map[Dialect]string{
+	Basic20: "BASIC 2.0",
+	Basic35: "BASIC 3.5",
+	Basic70: "BASIC 7.0",
+}
+
+func (d Dialect) String() string {
+	if str, ok := dialectStr[d]; ok {
+		return str
+	}
+	return "???"
+}
+
+// BASIC 3.5 keywords starting with token $cc. These are the same as in
+// BASIC 7.0 except that $ce is a keyword instead of a prefix.
+var tokens35 = func() []string {
+	list := append([]string{}, tokens70...)
+	list[2] = "RLUM"
+	return list
+}()
+
+// Load reads the contents of a PRG file by following the line links.
+func Load(data []byte) (*Program, error) {
+	if len(data) < 2 {
+		return nil, ErrTruncated
+	}
+	p := &Program{Start: int(data[0]) | int(data[1])<<8}
+	i := 2
+	for {
+		if i+2 > len(data) {
+			// Some files end without the final link
+			return p, nil
+		}
+		link := int(data[i]) | int(data[i+1])<<8
+		if link == 0 {
+			return p, nil
+		}
+		if i+4 > len(data) {
+			return nil, ErrTruncated
+		}
+		line := &Line{Num: int(data[i+2]) | int(data[i+3])<<8}
+		end := i + 4
+		for end < len(data) && data[end] != 0 {
+			end++
+		}
+		if end == len(data) {
+			return nil, ErrTruncated
+		}
+		line.Tokens = data[i+4 : end]
+		p.Lines = append(p.Lines, line)
+
+		next := link - p.Start + 2
+		if next <= end || next > len(data) {
+			return nil, ErrBadLink
+		}
+		i = next
+	}
+}
+
+// Dialect returns the version of BASIC for the program based on its
+// load address. BASIC 2.0 is used if the address is not recognized.
+func (p *Program) Dialect() Dialect {
+	switch p.Start {
+	case C128Start:
+		return Basic70
+	case Plus4Start:
+		return Basic35
+	}
+	return Basic20
+}
+
+// List returns the text of each line as shown by the LIST command.
+func (p *Program) List() []string {
+	d := p.Dialect()
+	list := make([]string, 0, len(p.Lines))
+	for _, line := range p.Lines {
+		list = append(list, strconv.Itoa(line.Num)+" "+Detokenize(line.Tokens, d))
+	}
+	return list
+}
+
+// Detokenize converts the contents of a line into text by expanding
+// the tokens found outside of strings and remarks. Control codes are
+// written as escapes, such as {clr}, and other characters that do not
+// have an ASCII equivalent are written as {$xx} with the hex value of
+// the character.
+func Detokenize(tokens []byte, d Dialect) string {
+	var b strings.Builder
+	quote := false
+	rem := false
+	for i := 0; i < len(tokens); i++ {
+		ch := tokens[i]
+		if ch == '"' {
+			quote = !quote
+		}
+		if quote || rem || ch < 0x80 || ch == tokenPi {
+			n := 1
+			if _, named := petscii.ControlName(ch); named {
+				for i+n < len(tokens) && tokens[i+n] == ch && n < petscii.MaxRepeat {
+					n++
+				}
+			}
+			b.WriteString(fromPetscii(ch, n))
+			i += n - 1
+			continue
+		}
+		if d == Basic70 && (ch == prefixCE || ch == prefixFE) && i+1 < len(tokens) {
+			if kw, ok := extendedKeyword(ch, tokens[i+1]); ok {
+				b.WriteString(kw)
+				i++
+				continue
+			}
+		}
+		if kw, ok := keywordFor(ch, d); ok {
+			b.WriteString(kw)
+			if ch == tokenRem {
+				rem = true
+			}
+			continue
+		}
+		b.WriteString(fromPetscii(ch, 1))
+	}
+	return b.String()
+}
+
+func keywordFor(token byte, d Dialect) (string, bool) {
+	i := int(token)
+	if i-0x80 < len(tokens20) {
+		return tokens20[i-0x80], true
+	}
+	var list []string
+	switch d {
+	case Basic35:
+		list = tokens35
+	case Basic70:
+		list = tokens70
+	}
+	if i-0xcc < len(list) && list[i-0xcc] != "" {
+		return list[i-0xcc], true
+	}
+	return "", false
+}
+
+func extendedKeyword(prefix byte, token byte) (string, bool) {
+	list := tokensCE
+	if prefix == prefixFE {
+		list = tokensFE
+	}
+	i := int(token) - 2
+	if i < 0 || i >= len(list) || list[i] == "" {
+		return "", false
+	}
+	return list[i], true
+}
+
+// Convert a PETSCII character, repeated n times, to text
+func fromPetscii(ch byte, n int) string {
+	switch {
+	case ch >= ' ' && ch <= '_':
+		return strings.Repeat(string(rune(ch)), n)
+	case ch == tokenPi:
+		return strings.Repeat("π", n)
+	}
+	return petscii.FormatEscape(ch, n)
+}
@@ -0,0 +1,95 @@
+package basic
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestListRoundTrip(t *testing.T) {
+	src := []string{
+		`10 PRINT "HELLO":GOTO 10`,
+		`20 IF A>1 THEN B=POT(1):ELSE C=2`,
+		`30 DOPEN#1,"FILE":FAST`,
+		`40 REM PRINT "X`,
+		`50 DATA 1,"A",GOTO`,
+		`60 A=π*2`,
+		`70 PRINT "{clr}{3 right}{rvs on}HI{rvs off}{$a0}"`,
+	}
+	p, err := Tokenize(strings.NewReader(strings.Join(src, "\n")), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	p2, err := Load(p.Bytes())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p2.Dialect() != Basic70 {
+		t.Errorf("wanted BASIC 7.0 ; got %v", p2.Dialect())
+	}
+	got := p2.List()
+	if len(got) != len(src) {
+		t.Fatalf("wanted %v lines ; got %v", len(src), len(got))
+	}
+	for i := range src {
+		if src[i] != got[i] {
+			t.Errorf("wanted %v ; got %v", src[i], got[i])
+		}
+	}
+}
+
+func TestListC64(t *testing.T) {
+	data := []byte{
+		0x01, 0x08,
+		0x0c, 0x08, 0x0a, 0x00, 0x99, ' ', 0xc7, '(', '5', ')', 0x00,
+		0x14, 0x08, 0x14, 0x00, 0xce, 0xfe, 0x3a, 0x00,
+		0x00, 0x00,
+	}
+	p, err := Load(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.Dialect() != Basic20 {
+		t.Errorf("wanted BASIC 2.0 ; got %v", p.Dialect())
+	}
+	want := []string{`10 PRINT CHR$(5)`, `20 {$ce}{$fe}:`}
+	got := p.List()
+	for i := range want {
+		if want[i] != got[i] {
+			t.Errorf("wanted %v ; got %v", want[i], got[i])
+		}
+	}
+}
+
+func TestListPlus4(t *testing.T) {
+	data := []byte{
+		0x01, 0x10,
+		0x08, 0x10, 0x0a, 0x00, 0xce, 0x00,
+		0x00, 0x00,
+	}
+	p, err := Load(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.Dialect() != Basic35 {
+		t.Errorf("wanted BASIC 3.5 ; got %v", p.Dialect())
+	}
+	if got := p.List()[0]; got != "10 RLUM" {
+		t.Errorf("wanted 10 RLUM ; got %v", got)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		data []byte
+		err  error
+	}{
+		{[]byte{0x01}, ErrTruncated},
+		{[]byte{0x01, 0x08, 0x0b, 0x08, 0x0a, 0x00, 0x99}, ErrTruncated},
+		{[]byte{0x01, 0x08, 0x03, 0x08, 0x0a, 0x00, 0x99, 0x00, 0x00, 0x00}, ErrBadLink},
+	}
+	for _, test := range tests {
+		if _, err := Load(test.data); err != test.err {
+			t.Errorf("% x: wanted %v ; got %v", test.data, test.err, err)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package basic
+
+import (
+	"github.com/blackchip-org/vt128/d71"
+)
+
+const (
+	// C64Start is where BASIC programs are loaded on the C64
+	C64Start = 0x0801
+
+	// C128Start is where BASIC programs are loaded on the C128
+	C128Start = 0x1c01
+
+	// Plus4Start is where BASIC programs are loaded on the Plus/4
+	Plus4Start = 0x1001
+)
+
+// Line is a tokenized line of a BASIC program.
+type Line struct {
+	Num    int    // Line number
+	Tokens []byte // Tokenized contents without the line number
+	Pos    Pos    // Where the line is found in the source
+}
+
+// Program is a list of tokenized lines in ascending order.
+type Program struct {
+	Start int // Load address
+	Lines []*Line
+}
+
+// NewProgram returns an empty program that loads on the C128.
+func NewProgram() *Program {
+	return &Program{Start: C128Start}
+}
+
+// Bytes returns the contents of the program as stored in a PRG file.
+// The first two bytes are the load address and each line is linked to
+// the address of the next line.
+func (p *Program) Bytes() []byte {
+	out := []byte{byte(p.Start), byte(p.Start >> 8)}
+	addr := p.Start
+	for _, line := range p.Lines {
+		// Link, line number, tokens, and the end of line marker
+		addr += 2 + 2 + len(line.Tokens) + 1
+		out = append(out, byte(addr), byte(addr>>8))
+		out = append(out, byte(line.Num), byte(line.Num>>8))
+		out = append(out, line.Tokens...)
+		out = append(out, 0)
+	}
+	return append(out, 0, 0) // End of program
+}
+
+// Save stores the program on the disk as a PRG file with the given
+// name.
+func (p *Program) Save(d d71.Disk, name string) error {
+	w, err := d.Create(name, d71.Prg)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(p.Bytes()); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
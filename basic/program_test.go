@@ -0,0 +1,55 @@
+package basic
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/blackchip-org/vt128/d71"
+)
+
+const helloSrc = `
+10 PRINT "HELLO"
+20 GOTO 10
+`
+
+func TestBytes(t *testing.T) {
+	p, err := Tokenize(strings.NewReader(helloSrc), "hello.bas")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []byte{
+		0x01, 0x1c, // Load address
+		0x0f, 0x1c, 0x0a, 0x00, 0x99, ' ', '"', 'H', 'E', 'L', 'L', 'O', '"', 0x00,
+		0x18, 0x1c, 0x14, 0x00, 0x89, ' ', '1', '0', 0x00,
+		0x00, 0x00,
+	}
+	got := p.Bytes()
+	if !bytes.Equal(want, got) {
+		t.Fatalf("\nwanted % x\ngot    % x", want, got)
+	}
+	if pos := p.Lines[1].Pos; pos.Line != 3 || pos.File != "hello.bas" {
+		t.Errorf("unexpected position: %v", pos)
+	}
+}
+
+func TestSave(t *testing.T) {
+	p, _ := Tokenize(strings.NewReader(helloSrc), "hello.bas")
+	d := d71.NewDisk("", "")
+	if err := p.Save(d, "HELLO"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r, err := d.Open("HELLO")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, _ := ioutil.ReadAll(r)
+	if !bytes.Equal(data, p.Bytes()) {
+		t.Fatalf("contents do not match")
+	}
+	fi, _ := d.Find("HELLO")
+	if fi.Type != d71.Prg {
+		t.Errorf("wanted PRG ; got %v", fi.Type)
+	}
+}
@@ -0,0 +1,79 @@
+package basic
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// SourceMapVersion is the version of the source map format written
+const SourceMapVersion = 1
+
+// SourceMap relates the lines of a program to where they are found in
+// the source. It is stored as JSON alongside the tokenized program.
+type SourceMap struct {
+	Version int          `json:"version"`
+	Lines   []SourceLine `json:"lines"`
+}
+
+// SourceLine is the location in the source for a program line.
+type SourceLine struct {
+	Num  int    `json:"num"`  // BASIC line number
+	File string `json:"file"` // Source file
+	Line int    `json:"line"` // Line in the source file, starting at one
+	Col  int    `json:"col"`  // Column in the source line, starting at one
+}
+
+// Pos returns the position in the source.
+func (s SourceLine) Pos() Pos {
+	return Pos{File: s.File, Line: s.Line, Col: s.Col}
+}
+
+// SourceMap returns the source location of each line in the program.
+func (p *Program) SourceMap() *SourceMap {
+	m := &SourceMap{Version: SourceMapVersion}
+	m.Lines = make([]SourceLine, 0, len(p.Lines))
+	for _, line := range p.Lines {
+		m.Lines = append(m.Lines, SourceLine{
+			Num:  line.Num,
+			File: line.Pos.File,
+			Line: line.Pos.Line,
+			Col:  line.Pos.Col,
+		})
+	}
+	return m
+}
+
+// Lookup returns the source location for the given BASIC line number.
+func (m *SourceMap) Lookup(num int) (SourceLine, bool) {
+	i := sort.Search(len(m.Lines), func(i int) bool {
+		return m.Lines[i].Num >= num
+	})
+	if i < len(m.Lines) && m.Lines[i].Num == num {
+		return m.Lines[i], true
+	}
+	return SourceLine{}, false
+}
+
+// Write stores the source map as JSON.
+func (m *SourceMap) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(m)
+}
+
+// ReadSourceMap loads a source map stored as JSON.
+func ReadSourceMap(r io.Reader) (*SourceMap, error) {
+	m := &SourceMap{}
+	if err := json.NewDecoder(r).Decode(m); err != nil {
+		return nil, err
+	}
+	if m.Version != SourceMapVersion {
+		return nil, fmt.Errorf("unsupported source map version: %v", m.Version)
+	}
+	sort.Slice(m.Lines, func(i, j int) bool {
+		return m.Lines[i].Num < m.Lines[j].Num
+	})
+	return m, nil
+}
@@ -0,0 +1,41 @@
+package basic
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSourceMap(t *testing.T) {
+	src := "10 PRINT \"A\"\n\n  30 GOTO 10\n"
+	p, err := Tokenize(strings.NewReader(src), "test.bas")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := p.SourceMap().Write(&buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m, err := ReadSourceMap(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	line, ok := m.Lookup(30)
+	if !ok {
+		t.Fatalf("line 30 not found")
+	}
+	want := SourceLine{Num: 30, File: "test.bas", Line: 3, Col: 1}
+	if line != want {
+		t.Errorf("wanted %+v ; got %+v", want, line)
+	}
+	if _, ok := m.Lookup(20); ok {
+		t.Errorf("line 20 should not be found")
+	}
+}
+
+func TestSourceMapVersion(t *testing.T) {
+	_, err := ReadSourceMap(strings.NewReader(`{"version": 99, "lines": []}`))
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+}
@@ -0,0 +1,195 @@
+package basic
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/blackchip-org/vt128/petscii"
+)
+
+const (
+	// MaxLineNum is the largest line number allowed
+	MaxLineNum = 63999
+
+	// MaxLineLen is the maximum number of tokenized bytes in a line
+	MaxLineLen = 255
+)
+
+// Pos is a location in a source file. Lines and columns start at one.
+type Pos struct {
+	File string
+	Line int
+	Col  int
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("%v:%v:%v", p.File, p.Line, p.Col)
+}
+
+// Error is a problem found in a source file.
+type Error struct {
+	Pos Pos
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%v: %v", e.Pos, e.Msg)
+}
+
+// Tokenize reads BASIC 7.0 source text and returns the program. Each
+// line starts with a line number and lines must be in ascending order.
+// Blank lines are ignored. Keywords may be written in upper or lower
+// case and are not tokenized inside strings or after REM and DATA.
+func Tokenize(r io.Reader, filename string) (*Program, error) {
+	p := NewProgram()
+	s := bufio.NewScanner(r)
+	lineNo := 0
+	for s.Scan() {
+		lineNo++
+		text := strings.TrimRight(s.Text(), " \t\r")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		line, err := TokenizeLine(text, Pos{File: filename, Line: lineNo, Col: 1})
+		if err != nil {
+			return nil, err
+		}
+		if n := len(p.Lines); n > 0 && line.Num <= p.Lines[n-1].Num {
+			return nil, &Error{Pos: line.Pos, Msg: fmt.Sprintf("line %v out of order", line.Num)}
+		}
+		p.Lines = append(p.Lines, line)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// TokenizeLine converts one line of source text that starts with a line
+// number. The position is where the text is found in the source.
+func TokenizeLine(text string, pos Pos) (*Line, error) {
+	line := &Line{Pos: pos}
+	i := 0
+	errorAt := func(at int, format string, args ...interface{}) error {
+		p := pos
+		p.Col += utf8.RuneCountInString(text[:at])
+		return &Error{Pos: p, Msg: fmt.Sprintf(format, args...)}
+	}
+
+	for i < len(text) && (text[i] == ' ' || text[i] == '\t') {
+		i++
+	}
+	start := i
+	for i < len(text) && text[i] >= '0' && text[i] <= '9' {
+		line.Num = line.Num*10 + int(text[i]-'0')
+		if line.Num > MaxLineNum {
+			return nil, errorAt(start, "line number too large")
+		}
+		i++
+	}
+	if i == start {
+		return nil, errorAt(start, "missing line number")
+	}
+	for i < len(text) && (text[i] == ' ' || text[i] == '\t') {
+		i++
+	}
+
+	var out []byte
+	quote := false
+	data := false
+	rem := false
+	for i < len(text) {
+		ch, size := utf8.DecodeRuneInString(text[i:])
+		if ch == '{' {
+			codes, n, err := petscii.ParseEscape(text[i:])
+			if err != nil {
+				return nil, errorAt(i, "%v", err)
+			}
+			out = append(out, codes...)
+			i += n
+			continue
+		}
+		switch {
+		case ch == '"':
+			quote = !quote
+		case quote || rem:
+		case data:
+			if ch == ':' {
+				data = false
+			}
+		case ch == '?':
+			out = append(out, 0x99) // Shorthand for PRINT
+			i += size
+			continue
+		case ch >= '0' && ch <= ';':
+			// Digits, colon and semicolon are never part of a keyword
+		default:
+			if kw, ok := matchKeyword(text[i:]); ok {
+				out = append(out, kw.token...)
+				i += len(kw.text)
+				switch kw.token[0] {
+				case tokenData:
+					data = true
+				case tokenRem:
+					rem = true
+				}
+				continue
+			}
+		}
+		b, ok := toPetscii(ch)
+		if !ok {
+			return nil, errorAt(i, "unsupported character: %q", ch)
+		}
+		out = append(out, b)
+		i += size
+	}
+	if len(out) > MaxLineLen {
+		return nil, errorAt(0, "line too long")
+	}
+	line.Tokens = out
+	return line, nil
+}
+
+// Keyword returns the BASIC 7.0 keyword, in uppercase, found at the
+// start of the text. The longest keyword is used when more than one
+// matches, the same way the text is tokenized.
+func Keyword(text string) (string, bool) {
+	kw, ok := matchKeyword(text)
+	return kw.text, ok
+}
+
+// Find the longest keyword at the start of the text
+func matchKeyword(text string) (keyword, bool) {
+	var match keyword
+	found := false
+	for _, kw := range keywords70 {
+		if len(kw.text) <= len(match.text) || len(kw.text) > len(text) {
+			continue
+		}
+		if strings.EqualFold(text[:len(kw.text)], kw.text) {
+			match = kw
+			found = true
+		}
+	}
+	return match, found
+}
+
+// Convert a character to PETSCII. Letters in either case are stored as
+// unshifted letters which appear as uppercase with the default
+// character set. Tabs are stored as spaces.
+func toPetscii(ch rune) (byte, bool) {
+	switch {
+	case ch >= 'a' && ch <= 'z':
+		return byte(ch - 'a' + 'A'), true
+	case ch >= ' ' && ch <= '_':
+		return byte(ch), true
+	case ch == '\t':
+		return ' ', true
+	case ch == 'π':
+		return tokenPi, true
+	}
+	return 0, false
+}
@@ -0,0 +1,70 @@
+package basic
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTokenizeLine(t *testing.T) {
+	tests := []struct {
+		text string
+		num  int
+		want []byte
+	}{
+		{`10 PRINT "HI"`, 10, []byte{0x99, ' ', '"', 'H', 'I', '"'}},
+		{`20 print "hi"`, 20, []byte{0x99, ' ', '"', 'H', 'I', '"'}},
+		{`30 ?A`, 30, []byte{0x99, 'A'}},
+		{`40 GOTO 10`, 40, []byte{0x89, ' ', '1', '0'}},
+		{`50 GO TO 10`, 50, []byte{0xcb, ' ', 0xa4, ' ', '1', '0'}},
+		{`60 IF A>1 THEN B=2`, 60, []byte{0x8b, ' ', 'A', 0xb1, '1', ' ', 0xa7, ' ', 'B', 0xb2, '2'}},
+		{`70 INPUT#1,A$`, 70, []byte{0x84, '1', ',', 'A', '$'}},
+		{`80 REM PRINT GOTO`, 80, append([]byte{0x8f}, " PRINT GOTO"...)},
+		{`90 DATA PRINT,"A:B":PRINT`, 90, append([]byte{0x83}, " PRINT,\"A:B\":\x99"...)},
+		{`100 X=POT(1)`, 100, []byte{'X', 0xb2, 0xce, 0x02, '(', '1', ')'}},
+		{`110 DOPEN#1,"F"`, 110, []byte{0xfe, 0x0d, '#', '1', ',', '"', 'F', '"'}},
+		{`120 DO:LOOP UNTIL X`, 120, []byte{0xeb, ':', 0xec, ' ', 0xfc, ' ', 'X'}},
+		{`130 FAST:SLOW`, 130, []byte{0xfe, 0x25, ':', 0xfe, 0x26}},
+		{`140 A=π`, 140, []byte{'A', 0xb2, 0xff}},
+		{`   150    END`, 150, []byte{0x80}},
+		{`160 PRINT "`, 160, []byte{0x99, ' ', '"'}},
+		{`170 PRINT "{clr}{2 down}{$a0}"`, 170, []byte{0x99, ' ', '"', 0x93, 0x11, 0x11, 0xa0, '"'}},
+	}
+	for _, test := range tests {
+		line, err := TokenizeLine(test.text, Pos{})
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", test.text, err)
+			continue
+		}
+		if line.Num != test.num {
+			t.Errorf("%v: wanted line %v ; got %v", test.text, test.num, line.Num)
+		}
+		if !bytes.Equal(test.want, line.Tokens) {
+			t.Errorf("%v: wanted % x ; got % x", test.text, test.want, line.Tokens)
+		}
+	}
+}
+
+func TestTokenizeErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"PRINT", "test.bas:1:1: missing line number"},
+		{"10 PRINT\n\n  64000 END", "test.bas:3:3: line number too large"},
+		{"10 PRINT \"€\"", "test.bas:1:11: unsupported character: '€'"},
+		{"10 PRINT \"{nope}\"", "test.bas:1:11: unknown escape: {nope}"},
+		{"10 END\n10 END", "test.bas:2:1: line 10 out of order"},
+		{"10 REM " + strings.Repeat("X", 255), "test.bas:1:1: line too long"},
+	}
+	for _, test := range tests {
+		_, err := Tokenize(strings.NewReader(test.src), "test.bas")
+		if err == nil {
+			t.Errorf("%q: expected error", test.src)
+			continue
+		}
+		if err.Error() != test.want {
+			t.Errorf("%q: wanted %v ; got %v", test.src, test.want, err)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Package basic converts between Commodore BASIC source text and the
+// tokenized form stored in PRG files.
+package basic
+
+const (
+	// Prefix for the extended functions added in BASIC 7.0
+	prefixCE = 0xce
+
+	// Prefix for the extended statements added in BASIC 7.0
+	prefixFE = 0xfe
+
+	tokenData = 0x83
+	tokenRem  = 0x8f
+	tokenPi   = 0xff
+)
+
+// BASIC 2.0 keywords starting with token $80
+var tokens20 = []string{
+	"END", "FOR", "NEXT", "DATA", "INPUT#", "INPUT", "DIM", "READ",
+	"LET", "GOTO", "RUN", "IF", "RESTORE", "GOSUB", "RETURN", "REM",
+	"STOP", "ON", "WAIT", "LOAD", "SAVE", "VERIFY", "DEF", "POKE",
+	"PRINT#", "PRINT", "CONT", "LIST", "CLR", "CMD", "SYS", "OPEN",
+	"CLOSE", "GET", "NEW", "TAB(", "TO", "FN", "SPC(", "THEN",
+	"NOT", "STEP", "+", "-", "*", "/", "^", "AND",
+	"OR", ">", "=", "<", "SGN", "INT", "ABS", "USR",
+	"FRE", "POS", "SQR", "RND", "LOG", "EXP", "COS", "SIN",
+	"TAN", "ATN", "PEEK", "LEN", "STR$", "VAL", "ASC", "CHR$",
+	"LEFT$", "RIGHT$", "MID$", "GO",
+}
+
+// BASIC 7.0 keywords starting with token $cc. Token $ce is the prefix
+// for extended functions and $fe is the prefix for extended statements.
+var tokens70 = []string{
+	"RGR", "RCLR", "", "JOY", "RDOT", "DEC", "HEX$", "ERR$",
+	"INSTR", "ELSE", "RESUME", "TRAP", "TRON", "TROFF", "SOUND", "VOL",
+	"AUTO", "PUDEF", "GRAPHIC", "PAINT", "CHAR", "BOX", "CIRCLE", "GSHAPE",
+	"SSHAPE", "DRAW", "LOCATE", "COLOR", "SCNCLR", "SCALE", "HELP", "DO",
+	"LOOP", "EXIT", "DIRECTORY", "DSAVE", "DLOAD", "HEADER", "SCRATCH", "COLLECT",
+	"COPY", "RENAME", "BACKUP", "DELETE", "RENUMBER", "KEY", "MONITOR", "USING",
+	"UNTIL", "WHILE",
+}
+
+// BASIC 7.0 extended functions starting with $ce $02
+var tokensCE = []string{
+	"POT", "BUMP", "PEN", "RSPPOS", "RSPRITE", "RSPCOLOR", "XOR", "RWINDOW",
+	"POINTER",
+}
+
+// BASIC 7.0 extended statements starting with $fe $02
+var tokensFE = []string{
+	"BANK", "FILTER", "PLAY", "TEMPO", "MOVSPR", "SPRITE", "SPRCOLOR", "RREG",
+	"ENVELOPE", "SLEEP", "CATALOG", "DOPEN", "APPEND", "DCLOSE", "BSAVE", "BLOAD",
+	"RECORD", "CONCAT", "DVERIFY", "DCLEAR", "SPRSAV", "COLLISION", "BEGIN", "BEND",
+	"WINDOW", "BOOT", "WIDTH", "SPRDEF", "QUIT", "STASH", "", "FETCH",
+	"", "SWAP", "OFF", "FAST", "SLOW",
+}
+
+// A keyword and the bytes it is tokenized to
+type keyword struct {
+	text  string
+	token []byte
+}
+
+// Keywords recognized when tokenizing BASIC 7.0
+var keywords70 = newKeywords()
+
+func newKeywords() []keyword {
+	var list []keyword
+	add := func(names []string, prefix int, first int) {
+		for i, name := range names {
+			if name == "" {
+				continue
+			}
+			var token []byte
+			if prefix != 0 {
+				token = append(token, byte(prefix))
+			}
+			token = append(token, byte(first+i))
+			list = append(list, keyword{text: name, token: token})
+		}
+	}
+	add(tokens20, 0, 0x80)
+	add(tokens70, 0, 0xcc)
+	add(tokensCE, prefixCE, 0x02)
+	add(tokensFE, prefixFE, 0x02)
+	return list
+}
@@ -74,7 +74,7 @@ func LoadDumpInto(src io.Reader, tgt []byte) error {
 }
 
 func lerrorf(line int, format string, args ...interface{}) error {
-	return fmt.Errorf("%v: %v", line, fmt.Sprintf(format, args))
+	return fmt.Errorf("%v: %v", line, fmt.Sprintf(format, args...))
 }
 
 func lerror(line int, err error) error {
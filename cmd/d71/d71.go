@@ -1,13 +1,22 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/blackchip-org/vt128/ansi"
+	"github.com/blackchip-org/vt128/basic"
 	"github.com/blackchip-org/vt128/d71"
+	"github.com/blackchip-org/vt128/gcr"
+	"github.com/blackchip-org/vt128/petscii"
+	"github.com/blackchip-org/vt128/preproc"
 )
 
 const (
@@ -21,15 +30,66 @@ type commandInfo struct {
 
 var (
 	disk     string
+	charset  = petscii.Upper
 	commands = map[string]commandInfo{
-		"bam":    commandInfo{run: bam, help: "print block availability map"},
-		"create": commandInfo{run: create, help: "create a formatted disk"},
-		"dir":    commandInfo{run: dir, help: "list directory"},
+		"bam":      commandInfo{run: bam, help: "print block availability map"},
+		"basic":    commandInfo{run: basicSave, help: "tokenize a BASIC program and save it to the disk"},
+		"blocks":   commandInfo{run: blocks, help: "list the blocks used by a file"},
+		"cmd":      commandInfo{run: dosCommand, help: "send a command to the drive and print the status"},
+		"create":   commandInfo{run: create, help: "create a formatted disk"},
+		"dir":      commandInfo{run: dir, help: "list directory"},
+		"gcr":      commandInfo{run: gcrImage, help: "report on or convert a G64/G71 image"},
+		"get-text": commandInfo{run: getText, help: "copy a sequential file from the disk as text"},
+		"list":     commandInfo{run: list, help: "list a BASIC program"},
+		"number":   commandInfo{run: number, help: "print BASIC source with labels as numbered lines"},
+		"put-text": commandInfo{run: putText, help: "copy text to a sequential file on the disk"},
+		"where":    commandInfo{run: where, help: "print the source of a BASIC line using a source map"},
 	}
 )
 
+var formats = map[string]*d71.Format{
+	"d64":    d71.D64,
+	"d64-40": d71.D64Ext,
+	"d71":    d71.D71,
+	"d81":    d71.D81,
+}
+
 func init() {
 	flag.StringVar(&disk, "d", "disk.d71", "disk image to use")
+	flag.Var(charsetFlag{&charset}, "c", "character set for names: upper or lower")
+}
+
+type charsetFlag struct {
+	c *petscii.Charset
+}
+
+func (f charsetFlag) String() string {
+	if f.c == nil {
+		return ""
+	}
+	return f.c.String()
+}
+
+func (f charsetFlag) Set(v string) error {
+	switch v {
+	case "upper":
+		*f.c = petscii.Upper
+	case "lower":
+		*f.c = petscii.Lower
+	default:
+		return fmt.Errorf("unknown character set: %v", v)
+	}
+	return nil
+}
+
+// Convert a file name given on the command line to PETSCII
+func petsciiName(name string) string {
+	pname, err := charset.EncodeString(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v: invalid file name: %v\n", prog, err)
+		os.Exit(1)
+	}
+	return pname
 }
 
 func usage() {
@@ -70,13 +130,15 @@ func main() {
 
 func create(args []string) {
 	var (
-		force bool
-		name  string
-		id    string
+		force  bool
+		name   string
+		id     string
+		format string
 	)
 
 	fs := flag.NewFlagSet("create", flag.ExitOnError)
 	fs.BoolVar(&force, "f", false, "create disk if file already exists")
+	fs.StringVar(&format, "t", "d71", "image type: d64, d64-40, d71, or d81")
 	fs.StringVar(&name, "n", "", "name of the disk")
 	fs.StringVar(&id, "i", "", "disk id")
 	fs.Parse(args)
@@ -87,7 +149,12 @@ func create(args []string) {
 		os.Exit(1)
 	}
 
-	d := d71.NewDisk(name, id)
+	f, ok := formats[format]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "%v: unknown image type: %v\n", prog, format)
+		os.Exit(1)
+	}
+	d := d71.NewDiskFormat(f, name, id)
 	err = d.Export(disk)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v: unable to save image: %v\n", prog, err)
@@ -95,19 +162,448 @@ func create(args []string) {
 	}
 }
 
+// Options used when source with labels is converted to numbered lines
+func labelFlags(fs *flag.FlagSet) *preproc.Options {
+	opts := &preproc.Options{}
+	fs.IntVar(&opts.Start, "start", preproc.DefaultStart, "first line number when using labels")
+	fs.IntVar(&opts.Step, "step", preproc.DefaultStep, "line number increment when using labels")
+	fs.BoolVar(&opts.Mangle, "m", false, "replace long variable names when using labels")
+	return opts
+}
+
+// Assign line numbers to source with labels
+func processLabels(src string, opts *preproc.Options) *preproc.Result {
+	in, err := os.Open(src)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v: unable to open source: %v\n", prog, err)
+		os.Exit(1)
+	}
+	defer in.Close()
+	res, err := preproc.Process(in, src, *opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	return res
+}
+
+func number(args []string) {
+	var names bool
+
+	fs := flag.NewFlagSet("number", flag.ExitOnError)
+	fs.BoolVar(&names, "names", false, "print the variable names replaced instead")
+	opts := labelFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "%v: usage: number [options] <source.bas>\n", prog)
+		os.Exit(1)
+	}
+	res := processLabels(fs.Arg(0), opts)
+	if names {
+		fmt.Print(res.NameTable())
+		return
+	}
+	fmt.Print(res.Text())
+}
+
+func basicSave(args []string) {
+	var (
+		force   bool
+		labels  bool
+		mapFile string
+		noMap   bool
+	)
+
+	fs := flag.NewFlagSet("basic", flag.ExitOnError)
+	fs.BoolVar(&force, "f", false, "replace the file if it already exists")
+	fs.BoolVar(&labels, "l", false, "source uses labels instead of line numbers")
+	fs.StringVar(&mapFile, "map", "", "source map to write (default <source>.map.json)")
+	fs.BoolVar(&noMap, "nomap", false, "do not write a source map")
+	opts := labelFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fmt.Fprintf(os.Stderr, "%v: usage: basic [options] <source.bas> [name]\n", prog)
+		os.Exit(1)
+	}
+	src := fs.Arg(0)
+	name := strings.TrimSuffix(filepath.Base(src), filepath.Ext(src))
+	if fs.NArg() == 2 {
+		name = fs.Arg(1)
+	}
+	name = petsciiName(name)
+
+	d, err := d71.Import(disk)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v: unable to load disk: %v\n", prog, err)
+		os.Exit(1)
+	}
+	var p *basic.Program
+	if labels {
+		p, err = processLabels(src, opts).Program()
+	} else {
+		var in *os.File
+		in, err = os.Open(src)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v: unable to open source: %v\n", prog, err)
+			os.Exit(1)
+		}
+		defer in.Close()
+		p, err = basic.Tokenize(in, src)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v: %v\n", prog, err)
+		os.Exit(1)
+	}
+	if force {
+		if err := d.Remove(name); err != nil && !errors.Is(err, d71.ErrNotFound) {
+			fmt.Fprintf(os.Stderr, "%v: unable to replace file: %v\n", prog, err)
+			os.Exit(1)
+		}
+	}
+	if err := p.Save(d, name); err != nil {
+		fmt.Fprintf(os.Stderr, "%v: unable to save program: %v\n", prog, err)
+		os.Exit(1)
+	}
+	if err := d.Export(disk); err != nil {
+		fmt.Fprintf(os.Stderr, "%v: unable to save image: %v\n", prog, err)
+		os.Exit(1)
+	}
+	if !noMap {
+		if mapFile == "" {
+			mapFile = strings.TrimSuffix(src, filepath.Ext(src)) + ".map.json"
+		}
+		if err := writeSourceMap(mapFile, p); err != nil {
+			fmt.Fprintf(os.Stderr, "%v: unable to save source map: %v\n", prog, err)
+			os.Exit(1)
+		}
+	}
+}
+
+// Source files are stored relative to the directory of the source map
+// so that the map still works if the project is moved.
+func writeSourceMap(mapFile string, p *basic.Program) error {
+	m := p.SourceMap()
+	dir, err := filepath.Abs(filepath.Dir(mapFile))
+	if err != nil {
+		return err
+	}
+	for i, line := range m.Lines {
+		file, err := filepath.Abs(line.File)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		m.Lines[i].File = filepath.ToSlash(rel)
+	}
+	out, err := os.Create(mapFile)
+	if err != nil {
+		return err
+	}
+	if err := m.Write(out); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func where(args []string) {
+	var context int
+
+	fs := flag.NewFlagSet("where", flag.ExitOnError)
+	fs.IntVar(&context, "c", 2, "number of source lines to show before and after")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fmt.Fprintf(os.Stderr, "%v: usage: where [options] <source.map.json> <line>\n", prog)
+		os.Exit(1)
+	}
+	mapFile := fs.Arg(0)
+	num, err := strconv.Atoi(fs.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v: invalid line number: %v\n", prog, fs.Arg(1))
+		os.Exit(1)
+	}
+	in, err := os.Open(mapFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v: unable to open source map: %v\n", prog, err)
+		os.Exit(1)
+	}
+	m, err := basic.ReadSourceMap(in)
+	in.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v: unable to read source map: %v\n", prog, err)
+		os.Exit(1)
+	}
+	line, ok := m.Lookup(num)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "%v: line not found: %v\n", prog, num)
+		os.Exit(1)
+	}
+	src := filepath.Join(filepath.Dir(mapFile), filepath.FromSlash(line.File))
+	pos := line.Pos()
+	pos.File = src
+	fmt.Println(pos)
+	data, err := ioutil.ReadFile(src)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v: unable to read source: %v\n", prog, err)
+		os.Exit(1)
+	}
+	text := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	for i := line.Line - context; i <= line.Line+context; i++ {
+		if i < 1 || i > len(text) {
+			continue
+		}
+		marker := " "
+		if i == line.Line {
+			marker = ">"
+		}
+		fmt.Printf("%v %5d | %v\n", marker, i, strings.TrimRight(text[i-1], "\r"))
+		if i == line.Line && line.Col > 0 {
+			fmt.Printf("  %5v | %v^\n", "", strings.Repeat(" ", line.Col-1))
+		}
+	}
+}
+
+func list(args []string) {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "%v: usage: list <file>\n", prog)
+		os.Exit(1)
+	}
+	d, err := d71.Import(disk)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v: unable to load disk: %v\n", prog, err)
+		os.Exit(1)
+	}
+	r, err := d.Open(petsciiName(args[0]))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v: unable to open file: %v\n", prog, err)
+		os.Exit(1)
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v: unable to read file: %v\n", prog, err)
+		os.Exit(1)
+	}
+	p, err := basic.Load(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v: not a BASIC program: %v\n", prog, err)
+		os.Exit(1)
+	}
+	for _, line := range p.List() {
+		fmt.Println(line)
+	}
+}
+
+func getText(args []string) {
+	var (
+		raw  bool
+		opts petscii.TextOptions
+	)
+
+	fs := flag.NewFlagSet("get-text", flag.ExitOnError)
+	fs.BoolVar(&raw, "raw", false, "copy the contents without conversion")
+	fs.BoolVar(&opts.NoEscapes, "noesc", false, "remove control codes instead of writing escapes")
+	fs.Parse(args)
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fmt.Fprintf(os.Stderr, "%v: usage: get-text [options] <file> [output]\n", prog)
+		os.Exit(1)
+	}
+	opts.Charset = charset
+
+	d, err := d71.Import(disk)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v: unable to load disk: %v\n", prog, err)
+		os.Exit(1)
+	}
+	r, err := d.Open(petsciiName(fs.Arg(0)))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v: unable to open file: %v\n", prog, err)
+		os.Exit(1)
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v: unable to read file: %v\n", prog, err)
+		os.Exit(1)
+	}
+	if !raw {
+		data = []byte(petscii.ToText(data, opts))
+	}
+	if fs.NArg() == 1 {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := ioutil.WriteFile(fs.Arg(1), data, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "%v: unable to write output: %v\n", prog, err)
+		os.Exit(1)
+	}
+}
+
+func putText(args []string) {
+	var (
+		force bool
+		raw   bool
+		opts  petscii.TextOptions
+	)
+
+	fs := flag.NewFlagSet("put-text", flag.ExitOnError)
+	fs.BoolVar(&force, "f", false, "replace the file if it already exists")
+	fs.BoolVar(&raw, "raw", false, "copy the contents without conversion")
+	fs.BoolVar(&opts.NoEscapes, "noesc", false, "do not allow escapes for control codes")
+	fs.Parse(args)
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fmt.Fprintf(os.Stderr, "%v: usage: put-text [options] <input> [name]\n", prog)
+		os.Exit(1)
+	}
+	opts.Charset = charset
+	src := fs.Arg(0)
+	name := strings.TrimSuffix(filepath.Base(src), filepath.Ext(src))
+	if fs.NArg() == 2 {
+		name = fs.Arg(1)
+	}
+	name = petsciiName(name)
+
+	data, err := ioutil.ReadFile(src)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v: unable to read input: %v\n", prog, err)
+		os.Exit(1)
+	}
+	if !raw {
+		data, err = petscii.FromText(string(data), opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v: %v: %v\n", prog, src, err)
+			os.Exit(1)
+		}
+	}
+	d, err := d71.Import(disk)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v: unable to load disk: %v\n", prog, err)
+		os.Exit(1)
+	}
+	if force {
+		if err := d.Remove(name); err != nil && !errors.Is(err, d71.ErrNotFound) {
+			fmt.Fprintf(os.Stderr, "%v: unable to replace file: %v\n", prog, err)
+			os.Exit(1)
+		}
+	}
+	w, err := d.Create(name, d71.Seq)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v: unable to create file: %v\n", prog, err)
+		os.Exit(1)
+	}
+	if _, err := w.Write(data); err != nil {
+		fmt.Fprintf(os.Stderr, "%v: unable to write file: %v\n", prog, err)
+		os.Exit(1)
+	}
+	if err := w.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v: unable to write file: %v\n", prog, err)
+		os.Exit(1)
+	}
+	if err := d.Export(disk); err != nil {
+		fmt.Fprintf(os.Stderr, "%v: unable to save image: %v\n", prog, err)
+		os.Exit(1)
+	}
+}
+
 func dir(args []string) {
+	if len(args) > 1 {
+		fmt.Fprintf(os.Stderr, "%v: usage: dir [pattern]\n", prog)
+		os.Exit(1)
+	}
 	d, err := d71.Import(disk)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v: unable to load disk: %v\n", prog, err)
+		os.Exit(1)
+	}
+	name := "$"
+	if len(args) == 1 {
+		name = args[0]
+		if !strings.HasPrefix(name, "$") {
+			name = "$:" + name
+		}
+	}
+	listing, err := d.Directory(petsciiName(name))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v: unable to list directory: %v\n", prog, err)
+		os.Exit(1)
 	}
-	info := d.Info()
-	fmt.Printf("0 %v\"%-16v\" %2v %2v%v\n", ansi.Reverse, info.Name, info.ID,
-		info.DosType, ansi.Normal)
-	list := d.List()
-	for _, file := range list {
-		fmt.Printf("%-4d %-18v %v\n", file.Size, "\""+file.Name+"\"", file.Type)
+	for _, line := range listing.Lines {
+		fmt.Printf("%v %v\n", line.Num, dirText(line.Text))
+	}
+}
+
+// Convert the text of a directory line for display. Reverse video is
+// shown using the terminal and is turned off at the end of the line.
+func dirText(text string) string {
+	var b strings.Builder
+	reverse := false
+	start := 0
+	for i := 0; i <= len(text); i++ {
+		if i < len(text) && text[i] != 0x12 && text[i] != 0x92 {
+			continue
+		}
+		b.WriteString(charset.DecodeString(text[start:i]))
+		if i < len(text) {
+			reverse = text[i] == 0x12
+			if reverse {
+				b.WriteString(ansi.Reverse)
+			} else {
+				b.WriteString(ansi.Normal)
+			}
+		}
+		start = i + 1
+	}
+	if reverse {
+		b.WriteString(ansi.Normal)
+	}
+	return b.String()
+}
+
+func blocks(args []string) {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "%v: usage: blocks <file>\n", prog)
+		os.Exit(1)
+	}
+	d, err := d71.Import(disk)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v: unable to load disk: %v\n", prog, err)
+		os.Exit(1)
+	}
+	fi, err := d.Lookup(petsciiName(args[0]))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v: unable to find file: %v\n", prog, err)
+		os.Exit(1)
+	}
+	chain, err := d.Chain(fi.First)
+	for _, pos := range chain {
+		fmt.Printf("%2d %2d\n", pos.Track, pos.Sector)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v: %v\n", prog, err)
+		os.Exit(1)
+	}
+}
+
+func dosCommand(args []string) {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "%v: usage: cmd <command>\n", prog)
+		os.Exit(1)
+	}
+	d, err := d71.Import(disk)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v: unable to load disk: %v\n", prog, err)
+		os.Exit(1)
+	}
+	status := d.Command(petsciiName(args[0]))
+	fmt.Println(charset.DecodeString(status.Error()))
+	if err := d.Export(disk); err != nil {
+		fmt.Fprintf(os.Stderr, "%v: unable to save image: %v\n", prog, err)
+		os.Exit(1)
+	}
+	if status.Code >= 20 && status.Code != 73 {
+		os.Exit(1)
 	}
-	fmt.Printf("%-4d BLOCKS FREE\n", info.Free)
 }
 
 func bam(args []string) {
@@ -116,12 +612,28 @@ func bam(args []string) {
 		fmt.Fprintf(os.Stderr, "unable to load disk: %v\n", err)
 		os.Exit(1)
 	}
-	fmt.Println("            1         2         3         4         5         6         7")
-	fmt.Println("   1234567890123456789012345678901234567890123456789012345678901234567890")
-	for sector := 0; sector < d71.MaxTrackLen; sector++ {
+	f := d.Format()
+	maxTrackLen := 0
+	fmt.Print("   ")
+	for track := 1; track <= f.MaxTrack; track++ {
+		if track%10 == 0 {
+			fmt.Print(track / 10)
+		} else {
+			fmt.Print(" ")
+		}
+		if f.Geom[track].Sectors > maxTrackLen {
+			maxTrackLen = f.Geom[track].Sectors
+		}
+	}
+	fmt.Print("\n   ")
+	for track := 1; track <= f.MaxTrack; track++ {
+		fmt.Print(track % 10)
+	}
+	fmt.Println()
+	for sector := 0; sector < maxTrackLen; sector++ {
 		fmt.Printf("%2d ", sector)
-		for track := 1; track <= d71.MaxTrack; track++ {
-			sectorN := d71.Geom[track].Sectors
+		for track := 1; track <= f.MaxTrack; track++ {
+			sectorN := f.Geom[track].Sectors
 			if sector >= sectorN {
 				fmt.Print(" ")
 			} else if d.BamRead(track, sector) {
@@ -133,3 +645,81 @@ func bam(args []string) {
 		fmt.Println()
 	}
 }
+
+func gcrImage(args []string) {
+	var (
+		extract bool
+		encode  bool
+	)
+
+	fs := flag.NewFlagSet("gcr", flag.ExitOnError)
+	fs.BoolVar(&extract, "x", false, "convert the GCR image and save it as the disk")
+	fs.BoolVar(&encode, "e", false, "encode the disk and save it as the GCR image")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "%v: usage: gcr [-x | -e] <image.g71>\n", prog)
+		os.Exit(1)
+	}
+	filename := fs.Arg(0)
+
+	if encode {
+		d, err := d71.Import(disk)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v: unable to load disk: %v\n", prog, err)
+			os.Exit(1)
+		}
+		img, err := gcr.FromDisk(d)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v: unable to encode disk: %v\n", prog, err)
+			os.Exit(1)
+		}
+		if err := img.Export(filename); err != nil {
+			fmt.Fprintf(os.Stderr, "%v: unable to save image: %v\n", prog, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	img, err := gcr.Import(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v: unable to load image: %v\n", prog, err)
+		os.Exit(1)
+	}
+	if extract {
+		d, err := img.ToDisk()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v: unable to convert image: %v\n", prog, err)
+			os.Exit(1)
+		}
+		if err := d.Export(disk); err != nil {
+			fmt.Fprintf(os.Stderr, "%v: unable to save image: %v\n", prog, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Println("track  speed   len  syncs  sectors  errors")
+	for _, r := range img.Report() {
+		track := fmt.Sprintf("%d", r.Track)
+		if r.Half {
+			track += ".5"
+		}
+		speed := fmt.Sprintf("%d", r.Speed)
+		if r.Speed == gcr.VariableSpeed {
+			speed = "var"
+		}
+		badBlocks := 0
+		for _, s := range r.Sectors {
+			if s.Err != nil {
+				badBlocks++
+			}
+		}
+		fmt.Printf("%5v  %5v  %4d  %5d  %7d  %6d\n", track, speed, r.Len,
+			r.Syncs, len(r.Sectors), badBlocks)
+		for _, s := range r.Sectors {
+			if s.Err != nil {
+				fmt.Printf("       %02d/%02d: %v\n", s.Track, s.Sector, s.Err)
+			}
+		}
+	}
+}
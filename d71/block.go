@@ -0,0 +1,163 @@
+package d71
+
+import (
+	"io"
+)
+
+// Buffer is a block of drive memory used for direct access to the disk,
+// the same as a channel opened with the file name "#". Blocks are read
+// into the buffer with ReadBlock (U1) and written from the buffer with
+// WriteBlock (U2). Read and Write transfer bytes at the buffer pointer,
+// the same as GET# and PRINT#, and the pointer is moved with
+// SetPointer (B-P).
+type Buffer struct {
+	d    Disk
+	data [SectorLen]byte
+	ptr  int
+}
+
+// OpenBuffer returns an empty buffer for direct access to the disk.
+func (d Disk) OpenBuffer() *Buffer {
+	return &Buffer{d: d}
+}
+
+// Bytes returns the contents of the buffer.
+func (b *Buffer) Bytes() []byte {
+	return b.data[:]
+}
+
+// Pointer returns the position in the buffer of the next byte to be
+// read or written.
+func (b *Buffer) Pointer() int {
+	return b.ptr
+}
+
+// SetPointer moves the buffer pointer to the given position. Returns
+// ErrSyntax if the position is not in the buffer.
+func (b *Buffer) SetPointer(pos int) error {
+	if pos < 0 || pos >= SectorLen {
+		return ErrSyntax
+	}
+	b.ptr = pos
+	return nil
+}
+
+// Read copies bytes from the buffer starting at the buffer pointer.
+// Returns io.EOF once the pointer reaches the end of the buffer.
+func (b *Buffer) Read(p []byte) (int, error) {
+	if b.ptr >= SectorLen {
+		return 0, io.EOF
+	}
+	n := copy(p, b.data[b.ptr:])
+	b.ptr += n
+	return n, nil
+}
+
+// Write copies bytes into the buffer starting at the buffer pointer.
+// Returns io.ErrShortWrite if the bytes do not fit in the rest of the
+// buffer.
+func (b *Buffer) Write(p []byte) (int, error) {
+	n := copy(b.data[b.ptr:], p)
+	b.ptr += n
+	if n < len(p) {
+		return n, io.ErrShortWrite
+	}
+	return n, nil
+}
+
+// ReadBlock reads a whole block into the buffer and resets the buffer
+// pointer, the same as the U1 command. Returns ErrBadLink if the block
+// is not on the disk or a read error if the block is flagged as bad in
+// the error table.
+func (b *Buffer) ReadBlock(track int, sector int) error {
+	if err := b.d.checkBlock(track, sector); err != nil {
+		return err
+	}
+	if err := b.d.readCheck(track, sector); err != nil {
+		return err
+	}
+	e := b.d.Editor()
+	e.Seek(track, sector, 0)
+	for i := range b.data {
+		b.data[i] = byte(e.Read())
+	}
+	b.ptr = 0
+	return nil
+}
+
+// WriteBlock writes the whole buffer to a block and resets the buffer
+// pointer, the same as the U2 command. The block availability map is
+// not changed. Returns ErrBadLink if the block is not on the disk and
+// ErrWriteProtect if the disk is write protected.
+func (b *Buffer) WriteBlock(track int, sector int) error {
+	if err := b.d.checkBlock(track, sector); err != nil {
+		return err
+	}
+	if err := b.d.writeCheck(); err != nil {
+		return err
+	}
+	e := b.d.Editor()
+	e.Seek(track, sector, 0)
+	for _, val := range b.data {
+		e.Write(int(val))
+	}
+	b.ptr = 0
+	return nil
+}
+
+// AllocateBlock marks a block as in use in the block availability map,
+// the same as the B-A command. If the block is already in use, NO BLOCK
+// is returned with the track and sector of the next free block, or with
+// track and sector zero if there are no free blocks after it. Returns
+// ErrBadLink if the block is not on the disk.
+func (d Disk) AllocateBlock(track int, sector int) error {
+	if err := d.checkBlock(track, sector); err != nil {
+		return err
+	}
+	if err := d.writeCheck(); err != nil {
+		return err
+	}
+	if !d.BamRead(track, sector) {
+		next := nextFreeBlock(d, track, sector)
+		return newDOSError(65, next.Track, next.Sector)
+	}
+	d.BamWrite(track, sector, false)
+	return nil
+}
+
+// FreeBlock marks a block as free in the block availability map, the
+// same as the B-F command. Returns ErrBadLink if the block is not on the
+// disk.
+func (d Disk) FreeBlock(track int, sector int) error {
+	if err := d.checkBlock(track, sector); err != nil {
+		return err
+	}
+	if err := d.writeCheck(); err != nil {
+		return err
+	}
+	d.BamWrite(track, sector, true)
+	return nil
+}
+
+// Finds the first free block after the given block in the same way as
+// the drive does for B-A: later sectors on the same track and then each
+// following track from sector zero. The directory track is skipped.
+// Returns track and sector zero if there are no free blocks.
+func nextFreeBlock(d Disk, track int, sector int) Pos {
+	f := d.Format()
+	for t := track; t <= f.MaxTrack; t++ {
+		if f.system(t) {
+			continue
+		}
+		s := 0
+		if t == track {
+			s = sector + 1
+		}
+		for ; s < f.Geom[t].Sectors; s++ {
+			if d.BamRead(t, s) {
+				return Pos{Track: t, Sector: s}
+			}
+		}
+	}
+	return Pos{}
+}
@@ -0,0 +1,115 @@
+package d71
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestBufferBlocks(t *testing.T) {
+	d := NewDisk("", "")
+	b := d.OpenBuffer()
+	if _, err := b.Write([]byte("HELLO")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := b.WriteBlock(1, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !d.BamRead(1, 0) {
+		t.Errorf("wanted block to remain free")
+	}
+
+	b2 := d.OpenBuffer()
+	if err := b2.ReadBlock(1, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := b2.SetPointer(1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := ioutil.ReadAll(b2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(data) != SectorLen-1 || !bytes.HasPrefix(data, []byte("ELLO\x00")) {
+		t.Errorf("unexpected data: %q", data[:8])
+	}
+	if _, err := b2.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("wanted %v ; got %v", io.EOF, err)
+	}
+	if err := b2.SetPointer(SectorLen); err != ErrSyntax {
+		t.Errorf("wanted %v ; got %v", ErrSyntax, err)
+	}
+	if err := b2.ReadBlock(99, 0); !errors.Is(err, ErrBadLink) {
+		t.Errorf("wanted %v ; got %v", ErrBadLink, err)
+	}
+}
+
+func TestBufferFull(t *testing.T) {
+	b := NewDisk("", "").OpenBuffer()
+	b.SetPointer(SectorLen - 2)
+	n, err := b.Write([]byte{1, 2, 3})
+	if n != 2 || err != io.ErrShortWrite {
+		t.Errorf("wanted 2, %v ; got %v, %v", io.ErrShortWrite, n, err)
+	}
+}
+
+func TestAllocateBlock(t *testing.T) {
+	d := NewDisk("", "")
+	free := d.Info().Free
+	if err := d.AllocateBlock(17, 20); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d.Info().Free != free-1 {
+		t.Errorf("wanted %v free ; got %v", free-1, d.Info().Free)
+	}
+	err := d.AllocateBlock(17, 20)
+	if !errors.Is(err, ErrNoBlock) {
+		t.Fatalf("wanted %v ; got %v", ErrNoBlock, err)
+	}
+	want := "65,NO BLOCK,19,00"
+	if err.Error() != want {
+		t.Errorf("wanted %v ; got %v", want, err)
+	}
+	if err := d.FreeBlock(17, 20); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d.Info().Free != free {
+		t.Errorf("wanted %v free ; got %v", free, d.Info().Free)
+	}
+	if err := d.AllocateBlock(18, 0); err.Error() != "65,NO BLOCK,19,00" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := d.FreeBlock(36, 21); !errors.Is(err, ErrBadLink) {
+		t.Errorf("wanted %v ; got %v", ErrBadLink, err)
+	}
+}
+
+func TestAllocateBlockLast(t *testing.T) {
+	d := NewDiskFormat(D64, "", "")
+	d.AllocateBlock(35, 16)
+	if err := d.AllocateBlock(35, 16); err.Error() != "65,NO BLOCK,00,00" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestCommandBlock(t *testing.T) {
+	d := NewDisk("", "")
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		{"B-A 0 1 0", "00, OK,00,00"},
+		{"B-A:0,1,0", "65,NO BLOCK,01,01"},
+		{"B-F 0 1 0", "00, OK,00,00"},
+		{"B-A 0 99 0", "66,ILLEGAL TRACK OR SECTOR,99,00"},
+		{"B-A 0 1", "30,SYNTAX ERROR,00,00"},
+		{"B-X 0 1 0", "31,SYNTAX ERROR,00,00"},
+	}
+	for _, test := range tests {
+		if got := d.Command(test.cmd).Error(); got != test.want {
+			t.Errorf("%v: wanted %v ; got %v", test.cmd, test.want, got)
+		}
+	}
+}
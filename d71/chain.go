@@ -0,0 +1,53 @@
+package d71
+
+// Tracks the blocks visited while following a chain of blocks so that
+// damaged chains are not followed forever.
+type chainCheck struct {
+	d    Disk
+	f    *Format
+	seen map[Pos]bool
+}
+
+func newChainCheck(d Disk) *chainCheck {
+	return &chainCheck{d: d, f: d.Format(), seen: make(map[Pos]bool)}
+}
+
+// Returns an error if the block cannot be the next block in the chain:
+// ErrBadLink if the block is not on the disk, a Problem with
+// ErrChainLoop if the block was already visited, or a Problem with
+// ErrCrossLink if the block is reserved for the header or BAM.
+func (c *chainCheck) visit(track int, sector int) error {
+	if err := c.d.checkBlock(track, sector); err != nil {
+		return err
+	}
+	pos := Pos{Track: track, Sector: sector}
+	if c.seen[pos] {
+		return &Problem{Pos: pos, Err: ErrChainLoop}
+	}
+	if c.f.reserved(track, sector) {
+		return &Problem{Pos: pos, Err: ErrCrossLink}
+	}
+	c.seen[pos] = true
+	return nil
+}
+
+// Chain returns the blocks in the chain that starts at the given
+// position. If the chain cannot be followed to the end, the blocks
+// found before the problem are returned along with ErrBadLink, or a
+// Problem with ErrChainLoop or ErrCrossLink.
+func (d Disk) Chain(first Pos) ([]Pos, error) {
+	c := newChainCheck(d)
+	e := d.Editor()
+	chain := make([]Pos, 0)
+	track, sector := first.Track, first.Sector
+	for track != 0 {
+		if err := c.visit(track, sector); err != nil {
+			return chain, err
+		}
+		chain = append(chain, Pos{Track: track, Sector: sector})
+		e.Seek(track, sector, 0)
+		track = e.Read()
+		sector = e.Read()
+	}
+	return chain, nil
+}
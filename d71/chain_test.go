@@ -0,0 +1,151 @@
+package d71
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"testing"
+)
+
+// Link the block at from to the block at to
+func link(d Disk, from Pos, to Pos) {
+	e := d.Editor()
+	e.Seek(from.Track, from.Sector, 0)
+	e.Write(to.Track)
+	e.Write(to.Sector)
+}
+
+func TestChain(t *testing.T) {
+	d := NewDisk("", "")
+	writeFile(t, d, "FILE", make([]byte, 600))
+	fi, _ := d.Find("FILE")
+	chain, err := d.Chain(fi.First)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(chain) != 3 || chain[0] != fi.First {
+		t.Errorf("unexpected chain: %v", chain)
+	}
+}
+
+func TestChainLoop(t *testing.T) {
+	d := NewDisk("", "")
+	writeFile(t, d, "FILE", make([]byte, 600))
+	fi, _ := d.Find("FILE")
+	chain, _ := d.Chain(fi.First)
+	link(d, chain[2], chain[1])
+
+	got, err := d.Chain(fi.First)
+	var p *Problem
+	if !errors.As(err, &p) || p.Err != ErrChainLoop || p.Pos != chain[1] {
+		t.Fatalf("wanted loop at %v ; got %v", chain[1], err)
+	}
+	if len(got) != 3 {
+		t.Errorf("wanted 3 blocks before the loop ; got %v", got)
+	}
+
+	r, _ := d.Open("FILE")
+	if _, err := ioutil.ReadAll(r); !errors.Is(err, ErrChainLoop) {
+		t.Errorf("wanted %v ; got %v", ErrChainLoop, err)
+	}
+}
+
+func TestChainCrossLink(t *testing.T) {
+	d := NewDisk("", "")
+	writeFile(t, d, "FILE", make([]byte, 600))
+	fi, _ := d.Find("FILE")
+	link(d, fi.First, Pos{Track: DirTrack, Sector: 0})
+
+	_, err := d.Chain(fi.First)
+	want := "block is cross-linked: track 18, sector 0"
+	if err == nil || err.Error() != want {
+		t.Errorf("wanted %v ; got %v", want, err)
+	}
+	r, _ := d.Open("FILE")
+	if _, err := ioutil.ReadAll(r); !errors.Is(err, ErrCrossLink) {
+		t.Errorf("wanted %v ; got %v", ErrCrossLink, err)
+	}
+}
+
+func TestDirWalkLoop(t *testing.T) {
+	d := NewDisk("", "")
+	writeFile(t, d, "FILE", []byte{1})
+	link(d, Pos{Track: DirTrack, Sector: 1}, Pos{Track: DirTrack, Sector: 1})
+
+	list, err := d.Files()
+	if !errors.Is(err, ErrChainLoop) {
+		t.Errorf("wanted %v ; got %v", ErrChainLoop, err)
+	}
+	if len(list) != 1 {
+		t.Errorf("wanted 1 file ; got %v", len(list))
+	}
+	if _, ok := d.Find("NOPE"); ok {
+		t.Errorf("wanted not found")
+	}
+}
+
+func TestScratchDirLoop(t *testing.T) {
+	d := NewDisk("", "")
+	for i := 0; i < 9; i++ {
+		writeFile(t, d, fmt.Sprintf("FILE %v", i), []byte{1})
+	}
+	link(d, Pos{Track: DirTrack, Sector: 1}, Pos{Track: DirTrack, Sector: 1})
+
+	n, err := d.Scratch("*")
+	if !errors.Is(err, ErrChainLoop) {
+		t.Errorf("wanted %v ; got %v", ErrChainLoop, err)
+	}
+	if n != 8 {
+		t.Errorf("wanted 8 files scratched ; got %v", n)
+	}
+	want := "66,ILLEGAL TRACK OR SECTOR,18,01"
+	if got := d.Command("S0:*").Error(); got != want {
+		t.Errorf("wanted %v ; got %v", want, got)
+	}
+}
+
+func TestOpenRelLoop(t *testing.T) {
+	d := NewDisk("", "")
+	f, err := d.CreateRel("REL", 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	f.Close()
+	fi, _ := d.Find("REL")
+	link(d, fi.First, fi.First)
+	if _, err := d.OpenRel("REL"); !errors.Is(err, ErrChainLoop) {
+		t.Errorf("wanted %v ; got %v", ErrChainLoop, err)
+	}
+	link(d, fi.First, Pos{})
+	link(d, fi.SideSector, fi.SideSector)
+	if _, err := d.OpenRel("REL"); !errors.Is(err, ErrChainLoop) {
+		t.Errorf("wanted %v ; got %v", ErrChainLoop, err)
+	}
+}
+
+func TestChainDOSError(t *testing.T) {
+	d := NewDisk("", "")
+	writeFile(t, d, "FILE", make([]byte, 600))
+	fi, _ := d.Find("FILE")
+	link(d, fi.First, fi.First)
+
+	r, err := d.Open("FILE")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err = ioutil.ReadAll(r)
+	var p *Problem
+	if !errors.As(err, &p) {
+		t.Fatalf("wanted problem ; got %v", err)
+	}
+	if errors.Is(err, ErrBadLink) || !errors.Is(err, ErrChainLoop) {
+		t.Errorf("wanted only %v ; got %v", ErrChainLoop, err)
+	}
+	want := fmt.Sprintf("66,ILLEGAL TRACK OR SECTOR,%02d,%02d", fi.First.Track, fi.First.Sector)
+	if got := p.DOSError().Error(); got != want {
+		t.Errorf("wanted %v ; got %v", want, got)
+	}
+	if got := dosStatus(err).Error(); got != want {
+		t.Errorf("wanted %v ; got %v", want, got)
+	}
+}
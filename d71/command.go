@@ -0,0 +1,249 @@
+package d71
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Status line reported on the error channel after the drive is reset
+var dosVersions = map[string]string{
+	"1541": "CBM DOS V2.6 1541",
+	"1571": "CBM DOS V3.0 1571",
+	"1581": "COPYRIGHT CBM DOS V10 1581",
+}
+
+// Command runs a command in the same way as the drive does when the
+// command is sent on channel 15 and returns the status that the drive
+// would report on the error channel afterwards. The status has a number
+// below 20 when the command was successful, or 73 with the DOS version
+// after a reset. The commands are:
+//
+//	N0:name,id    format the disk
+//	N0:name       clear the directory and keep the disk ID
+//	S0:pattern    scratch files
+//	R0:new=old    rename a file
+//	C0:new=old    copy a file, or concatenate with C0:new=a,b,...
+//	V0            validate the disk
+//	I0            initialize the drive
+//	UJ            reset the drive
+//	B-A 0 t s     allocate a block
+//	B-F 0 t s     free a block
+//
+// Only the first letter of a command is checked, so NEW0:name,id also
+// formats the disk. The drive number is ignored. Commands that use a
+// buffer channel, such as U1, U2 and B-P, are available through Buffer.
+func (d Disk) Command(cmd string) *DOSError {
+	if err := d.command(cmd); err != nil {
+		return err
+	}
+	return newDOSError(0, 0, 0)
+}
+
+func (d Disk) command(cmd string) *DOSError {
+	cmd = strings.TrimRight(cmd, "\r")
+	if cmd == "" {
+		return newDOSError(31, 0, 0)
+	}
+	args := ""
+	if i := strings.IndexByte(cmd, ':'); i >= 0 {
+		args = cmd[i+1:]
+	}
+	switch cmd[0] {
+	case 'N':
+		return dosStatus(d.commandNew(args))
+	case 'S':
+		if args == "" {
+			return newDOSError(34, 0, 0)
+		}
+		n, err := d.Scratch(args)
+		if err != nil {
+			return dosStatus(err)
+		}
+		return newDOSError(1, n, 0)
+	case 'R':
+		newName, oldName, ok := splitCommand(args)
+		if !ok {
+			return newDOSError(34, 0, 0)
+		}
+		return dosStatus(d.Rename(oldName, newName))
+	case 'C':
+		return dosStatus(d.commandCopy(args))
+	case 'V':
+		return dosStatus(d.commandValidate())
+	case 'I':
+		return nil
+	case 'B':
+		return dosStatus(d.commandBlock(cmd))
+	case 'U':
+		if cmd == "UJ" || cmd == "U:" {
+			status := newDOSError(73, 0, 0)
+			status.Msg = dosVersions[d.Format().Name]
+			return status
+		}
+	}
+	return newDOSError(31, 0, 0)
+}
+
+// Converts an error from a disk operation to the status reported by the
+// drive. A damaged chain is reported by its Problem as an illegal track
+// or sector.
+func dosStatus(err error) *DOSError {
+	if err == nil {
+		return nil
+	}
+	var p interface{ DOSError() *DOSError }
+	if errors.As(err, &p) {
+		return p.DOSError()
+	}
+	var de *DOSError
+	if errors.As(err, &de) {
+		return de
+	}
+	return newDOSError(20, 0, 0)
+}
+
+// Splits the arguments to a command that are written as new=old. The
+// drive number is removed from the old names.
+func splitCommand(args string) (newName string, oldName string, ok bool) {
+	i := strings.IndexByte(args, '=')
+	if i <= 0 || i == len(args)-1 {
+		return "", "", false
+	}
+	newName = args[:i]
+	var old []string
+	for _, name := range strings.Split(args[i+1:], ",") {
+		if j := strings.IndexByte(name, ':'); j >= 0 {
+			name = name[j+1:]
+		}
+		old = append(old, name)
+	}
+	return newName, strings.Join(old, ","), true
+}
+
+// Formats the disk in place. A full format is done when an ID is given,
+// otherwise only the header, BAM and directory are rewritten.
+func (d Disk) commandNew(args string) error {
+	if err := d.writeCheck(); err != nil {
+		return err
+	}
+	name, id := args, ""
+	full := false
+	if i := strings.IndexByte(args, ','); i >= 0 {
+		name, id = args[:i], args[i+1:]
+		full = true
+	}
+	if name == "" {
+		return newDOSError(34, 0, 0)
+	}
+	if len(name) > 0xf {
+		name = name[:0xf]
+	}
+	if len(id) > 2 {
+		id = id[:2]
+	}
+	f := d.Format()
+	if full {
+		for i := 0; i < f.Len; i++ {
+			d[i] = 0
+		}
+		for i := f.Len; i < len(d); i++ {
+			d[i] = ErrCodeOK
+		}
+	} else {
+		id = d.Info().ID
+		start := f.Offset(f.DirTrack, 0, 0)
+		end := start + f.Geom[f.DirTrack].Sectors*SectorLen
+		for i := start; i < end; i++ {
+			d[i] = 0
+		}
+	}
+	f.format(d, name, id)
+	return nil
+}
+
+// Copies a file, or joins several files together, into a new file of
+// the same type as the first file.
+func (d Disk) commandCopy(args string) error {
+	newName, oldNames, ok := splitCommand(args)
+	if !ok {
+		return newDOSError(34, 0, 0)
+	}
+	var data bytes.Buffer
+	var t FileType
+	for i, name := range strings.Split(oldNames, ",") {
+		fi, err := d.Lookup(name)
+		if err != nil {
+			return err
+		}
+		if fi.Type == Rel || fi.Type == Cbm {
+			return ErrFileType
+		}
+		if i == 0 {
+			t = fi.Type
+		}
+		r := newReader(d, fi.First.Track, fi.First.Sector)
+		if _, err := io.Copy(&data, r); err != nil {
+			return err
+		}
+	}
+	w, err := d.Create(newName, t)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data.Bytes()); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// Validates the disk and reports the first damaged chain found.
+func (d Disk) commandValidate() error {
+	if err := d.writeCheck(); err != nil {
+		return err
+	}
+	r := d.Validate()
+	if len(r.Problems) > 0 {
+		return &r.Problems[0]
+	}
+	return nil
+}
+
+// Runs B-A or B-F with the drive, track and sector given as numbers
+// separated by spaces or commas.
+func (d Disk) commandBlock(cmd string) error {
+	i := strings.IndexByte(cmd, '-')
+	if i < 0 || i == len(cmd)-1 {
+		return newDOSError(31, 0, 0)
+	}
+	op := cmd[i+1]
+	args := cmd[i+1:]
+	if j := strings.IndexAny(args, ": "); j >= 0 {
+		args = args[j+1:]
+	} else {
+		args = ""
+	}
+	params := strings.FieldsFunc(args, func(r rune) bool {
+		return r == ' ' || r == ',' || r == 0x1d
+	})
+	if len(params) != 3 {
+		return ErrSyntax
+	}
+	var nums [3]int
+	for j, param := range params {
+		n, err := strconv.Atoi(param)
+		if err != nil {
+			return ErrSyntax
+		}
+		nums[j] = n
+	}
+	switch op {
+	case 'A':
+		return d.AllocateBlock(nums[1], nums[2])
+	case 'F':
+		return d.FreeBlock(nums[1], nums[2])
+	}
+	return newDOSError(31, 0, 0)
+}
@@ -0,0 +1,114 @@
+package d71
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestCommandStatus(t *testing.T) {
+	d := NewDisk("TEST", "AB")
+	writeFile(t, d, "ONE", []byte{1, 2})
+	writeFile(t, d, "TWO", []byte{3})
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		{"I0", "00, OK,00,00"},
+		{"UJ", "73,CBM DOS V3.0 1571,00,00"},
+		{"R0:THREE=TWO", "00, OK,00,00"},
+		{"R0:THREE=TWO", "62,FILE NOT FOUND,00,00"},
+		{"R0:ONE=THREE", "63,FILE EXISTS,00,00"},
+		{"R0:ONE", "34,SYNTAX ERROR,00,00"},
+		{"C0:BOTH=ONE,0:THREE", "00, OK,00,00"},
+		{"C0:BOTH=ONE", "63,FILE EXISTS,00,00"},
+		{"S0:B*,ONE", "01, FILES SCRATCHED,02,00"},
+		{"S0:*=X", "30,SYNTAX ERROR,00,00"},
+		{"S0:", "34,SYNTAX ERROR,00,00"},
+		{"S", "34,SYNTAX ERROR,00,00"},
+		{"SCRATCH0", "34,SYNTAX ERROR,00,00"},
+		{"V0", "00, OK,00,00"},
+		{"X", "31,SYNTAX ERROR,00,00"},
+	}
+	for _, test := range tests {
+		if got := d.Command(test.cmd).Error(); got != test.want {
+			t.Errorf("%v: wanted %v ; got %v", test.cmd, test.want, got)
+		}
+	}
+	if got := fileNames(d.List()); got != "THREE" {
+		t.Errorf("unexpected files: %v", got)
+	}
+}
+
+func TestCommandCopy(t *testing.T) {
+	d := NewDisk("", "")
+	writeFile(t, d, "ONE", []byte{1, 2})
+	writeFile(t, d, "TWO", []byte{3})
+	if status := d.Command("C:BOTH=ONE,TWO"); status.Code != 0 {
+		t.Fatalf("unexpected status: %v", status)
+	}
+	r, err := d.Open("BOTH")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, _ := ioutil.ReadAll(r)
+	if string(data) != "\x01\x02\x03" {
+		t.Errorf("unexpected data: %v", data)
+	}
+}
+
+func TestCommandNew(t *testing.T) {
+	d := NewDisk("OLD", "AB")
+	writeFile(t, d, "FILE", make([]byte, 1000))
+	free := NewDisk("", "").Info().Free
+
+	if status := d.Command("N0:QUICK"); status.Code != 0 {
+		t.Fatalf("unexpected status: %v", status)
+	}
+	info := d.Info()
+	if info.Name != "QUICK" || info.ID != "AB" || info.Free != free {
+		t.Errorf("unexpected info: %+v", info)
+	}
+	if len(d.List()) != 0 {
+		t.Errorf("wanted no files ; got %v", fileNames(d.List()))
+	}
+
+	writeFile(t, d, "FILE", make([]byte, 1000))
+	if status := d.Command("NEW0:FULL,CD"); status.Code != 0 {
+		t.Fatalf("unexpected status: %v", status)
+	}
+	info = d.Info()
+	if info.Name != "FULL" || info.ID != "CD" || info.Free != free {
+		t.Errorf("unexpected info: %+v", info)
+	}
+	if len(d.List()) != 0 {
+		t.Errorf("wanted no files ; got %v", fileNames(d.List()))
+	}
+	if got := d.Command("N0:").Error(); got != "34,SYNTAX ERROR,00,00" {
+		t.Errorf("unexpected status: %v", got)
+	}
+}
+
+func TestCommandValidate(t *testing.T) {
+	d := NewDisk("", "")
+	writeFile(t, d, "FILE", make([]byte, 1000))
+	fi, _ := d.Find("FILE")
+	e := d.Editor()
+	e.Seek(fi.First.Track, fi.First.Sector, 0)
+	e.Write(99)
+	e.Write(0)
+	want := "66,ILLEGAL TRACK OR SECTOR,99,00"
+	if got := d.Command("V").Error(); got != want {
+		t.Errorf("wanted %v ; got %v", want, got)
+	}
+}
+
+func TestCommandWriteProtect(t *testing.T) {
+	d := NewDisk("", "").WithErrorTable()
+	d.SetBlockError(18, 0, ErrCodeWriteProtect)
+	want := "26,WRITE PROTECT ON,18,00"
+	for _, cmd := range []string{"N0:X,YY", "S0:*", "V0"} {
+		if got := d.Command(cmd).Error(); got != want {
+			t.Errorf("%v: wanted %v ; got %v", cmd, want, got)
+		}
+	}
+}
@@ -1,7 +1,5 @@
 package d71
 
-import "strings"
-
 type FileType int
 
 const (
@@ -10,6 +8,7 @@ const (
 	Prg                 // Program
 	Usr                 // User
 	Rel                 // Relative
+	Cbm                 // 1581 partition
 )
 
 const (
@@ -28,6 +27,7 @@ var fileTypeStr = map[FileType]string{
 	Prg: "PRG",
 	Usr: "USR",
 	Rel: "REL",
+	Cbm: "CBM",
 }
 
 func (f FileType) String() string {
@@ -38,38 +38,59 @@ func (f FileType) String() string {
 }
 
 type FileInfo struct {
-	Type   FileType //
-	SaveAt bool     // SAVE-@ operation
-	Locked bool     //
-	Splat  bool     // True if the file wasn't properly closed
-	Name   string   //
-	Size   int      // Number of sectors
-	First  Pos      // Location of first block
-	pos    Pos      // Position of this file entry in the directory
+	Type       FileType //
+	SaveAt     bool     // SAVE-@ operation
+	Locked     bool     //
+	Splat      bool     // True if the file wasn't properly closed
+	Name       string   //
+	Size       int      // Number of sectors
+	First      Pos      // Location of first block
+	SideSector Pos      // Location of first side sector (REL file only)
+	RecordLen  int      // Length of each record (REL file only)
+	pos        Pos      // Position of this file entry in the directory
 }
 
 type dirWalker struct {
 	skipDeleted bool // If false, returns deleted entries
 	e           *Editor
-	nextTrack   int  // Track of the next directory block or $00
-	nextSector  int  // Sector of the next directory block
-	entry       int  // Entry (0-7) in this sector that the walker is at
-	eof         bool //
+	nextTrack   int         // Track of the next directory block or $00
+	nextSector  int         // Sector of the next directory block
+	entry       int         // Entry (0-7) in this sector that the walker is at
+	eof         bool        //
+	err         error       // Set when a directory block cannot be read
+	chain       *chainCheck // Directory blocks already visited
 }
 
 func newDirWalker(d Disk) *dirWalker {
-	w := &dirWalker{e: d.Editor()}
+	w := &dirWalker{e: d.Editor(), chain: newChainCheck(d)}
 	w.skipDeleted = true
-	w.e.Seek(DirTrack, 0, 0)
+	if err := d.checkBlock(w.e.f.DirTrack, 0); err != nil {
+		w.err = err
+		w.eof = true
+		return w
+	}
+	w.e.Seek(w.e.f.DirTrack, 0, 0)
 
 	// BAM sector contains the location of the first directory block
 	firstTrack := w.e.Read()
 	firstSector := w.e.Read()
-	w.e.Seek(firstTrack, firstSector, 0)
-	w.readLink()
+	w.seek(firstTrack, firstSector)
 	return w
 }
 
+// Move to the start of a directory block. Stops the walker if the block
+// is not on the disk or was already visited.
+func (w *dirWalker) seek(track int, sector int) bool {
+	if err := w.chain.visit(track, sector); err != nil {
+		w.err = err
+		w.eof = true
+		return false
+	}
+	w.e.Seek(track, sector, 0)
+	w.readLink()
+	return true
+}
+
 // Remember the link for the next directory block
 func (w *dirWalker) readLink() {
 	e := w.e.Mark()
@@ -79,7 +100,8 @@ func (w *dirWalker) readLink() {
 
 // Advance the walker to the next directory entry. If at the last entry
 // for this sector, move to the next sector or return false indicating
-// the end of the listing.
+// the end of the listing. Also returns false if the next sector cannot
+// be read and the error is recorded in the walker.
 func (w *dirWalker) advance() bool {
 	w.entry++
 	if w.entry == 8 {
@@ -87,8 +109,7 @@ func (w *dirWalker) advance() bool {
 			return false
 		}
 		w.entry = 0
-		w.e.Seek(w.nextTrack, w.nextSector, 0)
-		w.readLink()
+		return w.seek(w.nextTrack, w.nextSector)
 	} else {
 		w.e.Move(0x20)
 	}
@@ -116,10 +137,34 @@ func (w *dirWalker) next() (*FileInfo, bool) {
 		}
 	}
 
+	fi := readFileInfo(w.e.Mark())
+
+	ok := w.advance()
+	if !ok {
+		w.eof = true
+	}
+	return fi, true
+}
+
+// Remove the shifted spaces used to pad a name. This is done a byte at
+// a time since names are PETSCII and not UTF-8.
+func trimPadding(s string) string {
+	start, end := 0, len(s)
+	for start < end && s[start] == 0xa0 {
+		start++
+	}
+	for end > start && s[end-1] == 0xa0 {
+		end--
+	}
+	return s[start:end]
+}
+
+// Read the directory entry found at the editor position
+func readFileInfo(e *Editor) *FileInfo {
 	fi := &FileInfo{}
-	fi.pos = w.e.Pos
+	fi.pos = e.Pos
 
-	e := w.e.Mark().Move(2)
+	e.Move(2)
 	ftype := e.Read()
 	fi.Type = FileType(ftype & 0x7)
 	fi.SaveAt = ftype&bitSaveAt > 0
@@ -127,15 +172,13 @@ func (w *dirWalker) next() (*FileInfo, bool) {
 	fi.Splat = ftype&bitSplat == 0
 	fi.First.Track = e.Read()
 	fi.First.Sector = e.Read()
-	fi.Name = strings.Trim(e.ReadString(16), "\xa0")
-	e.Move(0x1e - 0x15)
+	fi.Name = trimPadding(e.ReadString(16))
+	fi.SideSector.Track = e.Read()
+	fi.SideSector.Sector = e.Read()
+	fi.RecordLen = e.Read()
+	e.Move(0x1e - 0x18)
 	fi.Size = e.ReadWord()
-
-	ok := w.advance()
-	if !ok {
-		w.eof = true
-	}
-	return fi, true
+	return fi
 }
 
 func writeFileInfo(d Disk, fi *FileInfo) {
@@ -150,17 +193,18 @@ func writeFileInfo(d Disk, fi *FileInfo) {
 	if fi.Locked {
 		ftype = ftype | bitLocked
 	}
-	if fi.Splat {
+	if !fi.Splat {
 		ftype = ftype | bitSplat
 	}
 	e.Write(ftype)
 	e.Write(fi.First.Track)
 	e.Write(fi.First.Sector)
 	e.WriteStringN(fi.Name, 0xa0, MaxFilenameLen)
-	e.Move(2) // Track/Sector location of first side-sector block (REL file only)
-	e.Move(1) // REL file record length (REL file only, max. value 254)
-	e.Move(6) // $18-$1D: Unused (except with GEOS disks)
-	e.Write(fi.Size)
+	e.Write(fi.SideSector.Track)  // First side-sector block (REL file only)
+	e.Write(fi.SideSector.Sector) //
+	e.Write(fi.RecordLen)         // REL file record length (max. value 254)
+	e.Move(6)                     // $18-$1D: Unused (except with GEOS disks)
+	e.WriteWord(fi.Size)
 }
 
 func createDirEntry(d Disk) (*FileInfo, error) {
@@ -178,15 +222,30 @@ func createDirEntry(d Disk) (*FileInfo, error) {
 			return fi, nil
 		}
 	}
+	if w.err != nil {
+		return nil, w.err
+	}
 	// In this case, the last directory sector was full. Create a new
-	// one.
+	// one and link to it from the last sector.
 	dirSector, ok := freeDirSector(d)
 	if !ok {
 		return nil, ErrDirFull
 	}
+	e := d.Editor()
+	dirTrack := e.f.DirTrack
+	d.BamWrite(dirTrack, dirSector, false)
+	e.Seek(w.e.Track(), w.e.Sector(), 0)
+	e.Write(dirTrack)
+	e.Write(dirSector)
+
+	e.Seek(dirTrack, dirSector, 0)
+	e.Fill(0, SectorLen)
+	e.Seek(dirTrack, dirSector, 1)
+	e.Write(0xff)
+
 	fi := &FileInfo{
 		pos: Pos{
-			Track:  DirTrack,
+			Track:  dirTrack,
 			Sector: dirSector,
 		},
 	}
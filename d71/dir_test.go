@@ -223,3 +223,38 @@ func TestCreateDirEntryNextSector(t *testing.T) {
 		t.Fatalf("wanted %+v ; got %+v", wantPos, gotPos)
 	}
 }
+
+func TestDirWalkBadLink(t *testing.T) {
+	d := NewDisk("", "")
+	writeFile(t, d, "FILE", []byte{1})
+	// Link from the first directory block to track 99
+	e := d.Editor()
+	e.Seek(DirTrack, 1, 0)
+	e.Write(99)
+	e.Write(0)
+
+	list, err := d.Files()
+	want := "66,ILLEGAL TRACK OR SECTOR,99,00"
+	if err == nil || err.Error() != want {
+		t.Errorf("wanted %v ; got %v", want, err)
+	}
+	if len(list) != 1 || list[0].Name != "FILE" {
+		t.Errorf("wanted entries before the bad link ; got %v", list)
+	}
+	if _, err := d.Lookup("NOPE"); err == nil || err.Error() != want {
+		t.Errorf("wanted %v ; got %v", want, err)
+	}
+	if _, err := d.Directory("$"); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestDirWalkTruncated(t *testing.T) {
+	d := NewDisk("", "")[:Offset(DirTrack, 0, 0)]
+	if _, err := d.Files(); err == nil {
+		t.Errorf("expected error")
+	}
+	if _, err := d.ReadInfo(); err == nil {
+		t.Errorf("expected error")
+	}
+}
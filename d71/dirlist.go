@@ -0,0 +1,144 @@
+package d71
+
+import (
+	"bytes"
+	"strings"
+)
+
+const (
+	// DirStart is the load address of the directory program
+	DirStart = 0x0401
+
+	// Length of the text in each file line of the directory program
+	dirLineLen = 27
+
+	// Link written by the drive in each line. It is fixed up by the
+	// computer when the program is loaded.
+	dirLink = 0x0101
+)
+
+// DirLine is a line of the directory program. The line number is the
+// block count and the text is in PETSCII.
+type DirLine struct {
+	Num  int
+	Text string
+}
+
+// Directory is the program created by the drive when the directory is
+// loaded with LOAD"$",8. The first line is the header with the disk
+// name and the last line is the number of blocks free.
+type Directory struct {
+	Lines []DirLine
+}
+
+// Directory creates the directory program in the same way as the drive
+// does when a file name that starts with $ is loaded. Patterns can be
+// used to list only some of the files, such as $0:A*=P to list the
+// programs that start with A. Returns ErrSyntax if the name does not
+// start with $ and ErrBadLink if the header or directory cannot be
+// read.
+func (d Disk) Directory(name string) (*Directory, error) {
+	if !strings.HasPrefix(name, "$") {
+		return nil, ErrSyntax
+	}
+	spec := strings.TrimLeft(name[1:], "0123456789")
+	if spec != "" && spec[0] != ':' {
+		// Only a drive number can follow the $ without a colon
+		return nil, ErrSyntax
+	}
+	pattern, err := ParsePattern(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := d.ReadInfo()
+	if err != nil {
+		return nil, err
+	}
+	files, err := d.Files()
+	if err != nil {
+		return nil, err
+	}
+	dir := &Directory{}
+	dir.Lines = append(dir.Lines, dirHeader(d))
+	e := d.Editor()
+	for _, fi := range files {
+		if !pattern.Match(fi) {
+			continue
+		}
+		e.Pos = fi.pos
+		e.Move(5)
+		dir.Lines = append(dir.Lines, dirFileLine(fi, e.ReadString(MaxFilenameLen)))
+	}
+	free := DirLine{Num: info.Free, Text: "BLOCKS FREE." + strings.Repeat(" ", 13)}
+	dir.Lines = append(dir.Lines, free)
+	return dir, nil
+}
+
+// The header is the disk name in reverse video followed by the disk ID
+// and DOS type as they appear in the header sector.
+func dirHeader(d Disk) DirLine {
+	f := d.Format()
+	e := d.Editor()
+	e.Seek(f.DirTrack, 0, f.labelAt)
+	name := e.ReadString(16)
+	e.Move(2)
+	id := e.ReadString(5)
+	return DirLine{Num: 0, Text: "\x12\"" + name + "\" " + id}
+}
+
+// The file name is quoted up to the first shifted space. Anything in
+// the name after the shifted space appears after the closing quote.
+func dirFileLine(fi *FileInfo, rawName string) DirLine {
+	var b bytes.Buffer
+	switch {
+	case fi.Size < 10:
+		b.WriteString("   ")
+	case fi.Size < 100:
+		b.WriteString("  ")
+	case fi.Size < 1000:
+		b.WriteString(" ")
+	}
+	b.WriteByte('"')
+	if i := strings.IndexByte(rawName, 0xa0); i >= 0 {
+		b.WriteString(rawName[:i])
+		b.WriteByte('"')
+		b.WriteString(rawName[i+1:])
+		b.WriteByte(' ')
+	} else {
+		b.WriteString(rawName)
+		b.WriteByte('"')
+	}
+	if fi.Splat {
+		b.WriteByte('*')
+	} else {
+		b.WriteByte(' ')
+	}
+	b.WriteString(fi.Type.String())
+	if fi.Locked {
+		b.WriteByte('<')
+	}
+	for b.Len() < dirLineLen {
+		b.WriteByte(' ')
+	}
+	return DirLine{Num: fi.Size, Text: b.String()}
+}
+
+// Bytes returns the directory program as loaded into memory, starting
+// with the load address.
+func (dir *Directory) Bytes() []byte {
+	var b bytes.Buffer
+	b.WriteByte(DirStart & 0xff)
+	b.WriteByte(DirStart >> 8)
+	for _, line := range dir.Lines {
+		b.WriteByte(dirLink & 0xff)
+		b.WriteByte(dirLink >> 8)
+		b.WriteByte(byte(line.Num))
+		b.WriteByte(byte(line.Num >> 8))
+		b.WriteString(line.Text)
+		b.WriteByte(0)
+	}
+	b.WriteByte(0)
+	b.WriteByte(0)
+	return b.Bytes()
+}
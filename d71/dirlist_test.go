@@ -0,0 +1,105 @@
+package d71
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDirectory(t *testing.T) {
+	d := NewDisk("MY DISK", "MD")
+	writeFile(t, d, "FILE 1", make([]byte, 10))
+	writeFile(t, d, "DATA", make([]byte, 254*12))
+	fi, _ := d.Find("DATA")
+	fi.Locked = true
+	fi.Type = Seq
+	writeFileInfo(d, fi)
+
+	dir, err := d.Directory("$")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pad := func(s string, n int) string {
+		return s + strings.Repeat("\xa0", n)
+	}
+	want := []DirLine{
+		{0, "\x12\"" + pad("MY DISK", 9) + "\" MD\xa02A"},
+		{1, "   \"" + pad("FILE 1\"", 9) + "  PRG  "},
+		{12, "  \"" + pad("DATA\"", 11) + "  SEQ<  "},
+		{1315, "BLOCKS FREE.             "},
+	}
+	if len(dir.Lines) != len(want) {
+		t.Fatalf("wanted %v lines ; got %v", len(want), len(dir.Lines))
+	}
+	for i := range want {
+		if dir.Lines[i] != want[i] {
+			t.Errorf("wanted %v %q ; got %v %q", want[i].Num, want[i].Text, dir.Lines[i].Num, dir.Lines[i].Text)
+		}
+	}
+	for _, line := range dir.Lines[1:3] {
+		if len(line.Text) != dirLineLen {
+			t.Errorf("wanted length %v ; got %v", dirLineLen, len(line.Text))
+		}
+	}
+
+	data := dir.Bytes()
+	start := []byte{0x01, 0x04, 0x01, 0x01, 0x00, 0x00, 0x12, '"', 'M'}
+	if !bytes.HasPrefix(data, start) {
+		t.Errorf("wanted prefix % x ; got % x", start, data[:len(start)])
+	}
+	end := []byte{0x01, 0x01, 0x23, 0x05, 'B'}
+	if !bytes.Contains(data, end) {
+		t.Errorf("blocks free line not found")
+	}
+	if !bytes.HasSuffix(data, []byte{' ', 0, 0, 0}) {
+		t.Errorf("unexpected end: % x", data[len(data)-4:])
+	}
+	// Each file line uses 32 bytes
+	if n := 2 + 2*(4+25+1) + 2*32 + 2; len(data) != n {
+		t.Errorf("wanted %v bytes ; got %v", n, len(data))
+	}
+}
+
+func TestDirectoryPattern(t *testing.T) {
+	d := NewDisk("", "")
+	writeFile(t, d, "APPLE", []byte{1})
+	writeFile(t, d, "AXE", []byte{1})
+	writeFile(t, d, "BANANA", []byte{1})
+	fi, _ := d.Find("AXE")
+	fi.Type = Seq
+	writeFileInfo(d, fi)
+
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"$", []string{"APPLE", "AXE", "BANANA"}},
+		{"$0", []string{"APPLE", "AXE", "BANANA"}},
+		{"$0:A*", []string{"APPLE", "AXE"}},
+		{"$:A*=P", []string{"APPLE"}},
+		{"$:*=S", []string{"AXE"}},
+		{"$:A?E,B*", []string{"AXE", "BANANA"}},
+		{"$:APPL", nil},
+	}
+	for _, test := range tests {
+		dir, err := d.Directory(test.name)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", test.name, err)
+			continue
+		}
+		var got []string
+		for _, line := range dir.Lines[1 : len(dir.Lines)-1] {
+			text := strings.TrimLeft(line.Text, " ")
+			got = append(got, text[1:strings.IndexByte(text[1:], '"')+1])
+		}
+		if strings.Join(got, ",") != strings.Join(test.want, ",") {
+			t.Errorf("%v: wanted %v ; got %v", test.name, test.want, got)
+		}
+	}
+
+	for _, name := range []string{"FILE", "$X", "$:*=Q"} {
+		if _, err := d.Directory(name); err != ErrSyntax {
+			t.Errorf("%v: wanted %v ; got %v", name, ErrSyntax, err)
+		}
+	}
+}
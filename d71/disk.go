@@ -4,7 +4,8 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
-	"strings"
+
+	"github.com/blackchip-org/vt128/petscii"
 )
 
 const (
@@ -51,11 +52,11 @@ type DiskInfo struct {
 // Geom contains an entry for each track describing the number of sectors
 // and absolute offset into the disk. Since there is no track zero, that
 // index does not contain any useful information.
-var Geom []TrackInfo
+var Geom = newGeomD71()
 
 // Create the geometry table
-func init() {
-	Geom = make([]TrackInfo, 71, 71)
+func newGeomD71() []TrackInfo {
+	geom := make([]TrackInfo, 71, 71)
 
 	offset := 0
 	for i := 1; i <= 70; i++ {
@@ -80,14 +81,19 @@ func init() {
 		default:
 			panic(fmt.Sprintf("invalid track: %v", i))
 		}
-		Geom[i] = TrackInfo{Sectors: sectors, Offset: offset}
+		geom[i] = TrackInfo{Sectors: sectors, Offset: offset}
 		offset += (sectors * SectorLen)
 	}
+	return geom
 }
 
 // Offset computes the absolute disk byte offset based on a track, sector,
-// and sector offset
+// and sector offset. Returns -1 if the position is not on a 1571 disk.
 func Offset(track int, sector int, at int) int {
+	p := Pos{Track: track, Sector: sector, At: at}
+	if !p.valid(Geom) {
+		return -1
+	}
 	toff := Geom[track].Offset
 	return toff + (sector * SectorLen) + at
 }
@@ -98,25 +104,39 @@ type Pos struct {
 	At     int
 }
 
+// Offset returns the absolute disk byte offset of the position on a 1571
+// disk, or -1 if the position is not on the disk.
 func (p *Pos) Offset() int {
 	return Offset(p.Track, p.Sector, p.At)
 }
 
 func (p *Pos) Move(val int) {
+	p.move(Geom, val)
+}
+
+// Move the position by val bytes. If the position moves off of the
+// disk, it stops at the first track or sector that is not valid.
+func (p *Pos) move(geom []TrackInfo, val int) {
 	p.At += val
 	for p.At < 0 || p.At >= SectorLen {
+		if p.Track < 1 || p.Track >= len(geom) {
+			return
+		}
 		if p.At < 0 {
 			p.At = p.At + SectorLen
 			p.Sector--
 			if p.Sector < 0 {
 				p.Track--
-				p.Sector = Geom[p.Track].Sectors - 1
+				if p.Track < 1 {
+					return
+				}
+				p.Sector = geom[p.Track].Sectors - 1
 			}
 		}
 		if p.At >= SectorLen {
 			p.At = p.At - SectorLen
 			p.Sector++
-			if p.Sector >= Geom[p.Track].Sectors {
+			if p.Sector >= geom[p.Track].Sectors {
 				p.Track++
 				p.Sector = 0
 			}
@@ -124,16 +144,34 @@ func (p *Pos) Move(val int) {
 	}
 }
 
+// Valid returns true if the position can be found on a 1571 disk. Use
+// Format.Valid for other formats.
+func (p *Pos) Valid() bool {
+	return p.valid(Geom)
+}
+
+func (p *Pos) valid(geom []TrackInfo) bool {
+	return p.Track >= 1 && p.Track < len(geom) &&
+		p.Sector >= 0 && p.Sector < geom[p.Track].Sectors &&
+		p.At >= 0 && p.At < SectorLen
+}
+
 func (p *Pos) Seek(track int, sector int, at int) {
 	p.Track = track
 	p.Sector = sector
 	p.At = at
 }
 
-// A 1571 floppy disk. Use NewDisk for a formatted disk.
+// A disk image. Use NewDisk for a formatted 1571 disk or NewDiskFormat
+// for other formats.
 type Disk []byte
 
 func NewDisk(name string, id string) Disk {
+	return NewDiskFormat(D71, name, id)
+}
+
+// NewDiskFormat returns a formatted disk using the given format.
+func NewDiskFormat(f *Format, name string, id string) Disk {
 	if len(name) > 0xf {
 		name = name[:0xf]
 	}
@@ -141,71 +179,13 @@ func NewDisk(name string, id string) Disk {
 		id = id[:2]
 	}
 
-	d := make(Disk, DiskLen, DiskLen)
-	e := d.Editor()
-
-	e.Seek(18, 0, 0)
-	e.Write(18)   // Track of first directory sector
-	e.Write(1)    // Sector of first directory sector
-	e.Write(0x41) // Disk DOS version type. A = 1541
-	e.Write(0x80) // Double-sided flag, set to double-sided
-
-	// BAM, front Side
-	for i := 1; i < Flip; i++ {
-		sectors := Geom[i].Sectors
-		lastFree := 1<<(byte(sectors-16)) - 1
-		if i != DirTrack {
-			e.Write(sectors) // Sectors available
-			e.Write(0xff)    // Sectors 0 - 7 free
-		} else {
-			e.Write(sectors - 2) // BAM and first dir sector in use
-			e.Write(0xfc)        // First two sectors in use
-		}
-		e.Write(0xff)     // Sectors 8 - 15 free
-		e.Write(lastFree) // Remaining sectors free
-	}
-
-	e.WriteStringN(name, 0xa0, 0x10) // Disk Name
-	e.Fill(0xa0, 2)                  // Fill
-	e.WriteStringN(id, 0x20, 2)      // Disk ID
-	e.Write(0xa0)                    // Fill
-	e.WriteString("2A")              // DOS Type
-	e.Fill(0xa0, 0xaa-0xa7+1)        // Fill
-
-	// Free sector count of back side
-	e.Seek(18, 0, 0xdd)
-	for i := Flip; i <= MaxTrack; i++ {
-		if i != BamTrack {
-			sectors := Geom[i].Sectors
-			e.Write(sectors) // Sectors available
-		} else {
-			e.Write(0) // All sectors in use
-		}
-	}
-
-	// BAM, back side
-	e.Seek(53, 0, 0)
-	for i := Flip; i <= MaxTrack; i++ {
-		if i != BamTrack {
-			sectors := Geom[i].Sectors
-			lastFree := 1<<(byte(sectors-16)) - 1
-			e.Write(0xff)     // Sectors 0 - 7 free
-			e.Write(0xff)     // Sectors 8 - 15 free
-			e.Write(lastFree) // Remaining sectors free
-		} else {
-			e.Fill(0, 3) // All sectors marked as used
-		}
-	}
-
-	// Blank directory, set link to nothing
-	e.Seek(DirTrack, 1, 1)
-	e.Write(0xff)
-
+	d := make(Disk, f.Len, f.Len)
+	f.format(d, name, id)
 	return d
 }
 
 func (d Disk) Editor() *Editor {
-	e := &Editor{disk: d}
+	e := &Editor{disk: d, f: d.Format()}
 	e.Pos.Track = 1
 	return e
 }
@@ -220,8 +200,8 @@ func Import(filename string) (Disk, error) {
 	if err != nil {
 		return nil, err
 	}
-	if fi.Size() != DiskLen {
-		return nil, fmt.Errorf("File is not a D71 disk: %v", filename)
+	if !knownSize(int(fi.Size())) {
+		return nil, fmt.Errorf("unknown disk image format: %v", filename)
 	}
 	data, err := ioutil.ReadFile(filename)
 	if err != nil {
@@ -230,58 +210,90 @@ func Import(filename string) (Disk, error) {
 	return Disk(data), nil
 }
 
+// Info returns the information found in the header and the number of
+// blocks free. Use ReadInfo to find out if the header or BAM cannot be
+// read.
 func (d Disk) Info() DiskInfo {
+	di, _ := d.ReadInfo()
+	return di
+}
+
+// ReadInfo returns the same information as Info. Returns ErrBadLink if
+// the header or BAM blocks are not in the image.
+func (d Disk) ReadInfo() (DiskInfo, error) {
+	f := d.Format()
 	e := d.Editor()
 	di := DiskInfo{}
-	free := 0
-	e.Seek(DirTrack, 0, 2)
+	if err := d.checkBlock(f.DirTrack, 0); err != nil {
+		return di, err
+	}
+	e.Seek(f.DirTrack, 0, 2)
 	di.DosVersion = e.ReadString(1)
 	di.DoubleSided = e.Read() == 0x80
-	// Front side counts in BAM
-	for track := 1; track < Flip; track++ {
-		// Don't count directory sectors
-		if track == DirTrack {
-			e.Read()
-		} else {
-			free += e.Read()
-		}
-		e.Move(3)
-	}
-	di.Name = strings.Trim(e.ReadString(16), "\xa0")
+
+	e.Seek(f.DirTrack, 0, f.labelAt)
+	di.Name = trimPadding(e.ReadString(16))
 	e.Move(2)
 	di.ID = e.ReadString(2)
 	e.Move(1)
 	di.DosType = e.ReadString(2)
 
-	// Back side counts in aux area
-	e.Seek(DirTrack, 0, 0xdd)
-	for track := Flip; track <= MaxTrack; track++ {
-		// Don't count back side BAM track
-		if track == BamTrack {
-			e.Read()
-		} else {
-			free += e.Read()
+	// Don't count the directory track or the back side BAM track
+	for track := 1; track <= f.MaxTrack; track++ {
+		_, _, pos := f.bamEntry(f, track)
+		if err := d.checkBlock(pos.Track, pos.Sector); err != nil {
+			return di, err
+		}
+		if !f.system(track) {
+			di.Free += d.TrackInfo(track).Free
 		}
 	}
-	di.Free = free
-	return di
+	return di, nil
 }
 
+// TrackInfo returns the number of sectors and free sectors for a track.
+// Returns an empty TrackInfo if the track is not on the disk.
 func (d Disk) TrackInfo(track int) TrackInfo {
-	ti := Geom[track]
-	e := d.Editor()
-	if track < Flip {
-		e.Seek(DirTrack, 0, 4)
-		e.Move((track - 1) * 4)
-	} else {
-		e.Seek(DirTrack, 0, 0xdd)
-		e.Move((track - 36))
+	if d.checkBlock(track, 0) != nil {
+		return TrackInfo{}
 	}
+	e := d.Editor()
+	ti := e.f.Geom[track]
+	freeCountPos(e, track)
 	ti.Free = e.Read()
 	return ti
 }
 
-func (d Disk) List() []*FileInfo {
+// Move the editor to the byte that holds the number of free sectors
+// for the given track.
+func freeCountPos(e *Editor, track int) {
+	_, _, e.Pos = e.f.bamEntry(e.f, track)
+}
+
+// List returns the files on the disk. If patterns are given, only the
+// files that match at least one of them are returned. A pattern that
+// cannot be parsed does not match any files. If the directory cannot
+// be read, the files found before the problem are returned. Use Files
+// to find out if there was a problem.
+func (d Disk) List(patterns ...string) []*FileInfo {
+	list, _ := d.Files(patterns...)
+	return list
+}
+
+// Files returns the same files as List. Returns ErrSyntax if a pattern
+// cannot be parsed and ErrBadLink if a directory block is not on the
+// disk.
+func (d Disk) Files(patterns ...string) ([]*FileInfo, error) {
+	var parsed []Pattern
+	var err error
+	for _, s := range patterns {
+		p, perr := ParsePattern(s)
+		if perr != nil {
+			err = perr
+			continue
+		}
+		parsed = append(parsed, p)
+	}
 	w := newDirWalker(d)
 	list := make([]*FileInfo, 0, 0)
 	for {
@@ -289,53 +301,292 @@ func (d Disk) List() []*FileInfo {
 		if !more {
 			break
 		}
+		if len(patterns) > 0 && !matchAny(parsed, fi) {
+			continue
+		}
 		list = append(list, fi)
 	}
-	return list
+	if w.err != nil {
+		err = w.err
+	}
+	return list, err
+}
+
+func matchAny(patterns []Pattern, fi *FileInfo) bool {
+	for _, p := range patterns {
+		if p.Match(fi) {
+			return true
+		}
+	}
+	return false
+}
+
+// Find returns the first file that matches the pattern. See Pattern for
+// the wildcards that can be used. Returns false if there is no match,
+// the pattern cannot be parsed, or the directory cannot be read. Use
+// Lookup to find out why a file was not found.
+func (d Disk) Find(pattern string) (*FileInfo, bool) {
+	fi, err := d.Lookup(pattern)
+	return fi, err == nil
+}
+
+// Lookup returns the first file that matches the pattern. Returns
+// ErrNotFound if there is no match, ErrSyntax if the pattern cannot be
+// parsed or has no name, and ErrBadLink if a directory block is not on
+// the disk.
+func (d Disk) Lookup(pattern string) (*FileInfo, error) {
+	p, err := ParsePattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.Names) == 0 {
+		return nil, ErrSyntax
+	}
+	return d.find(p.Match)
+}
+
+// Returns the file with exactly the given name.
+func (d Disk) findExact(name string) (*FileInfo, bool) {
+	fi, err := d.find(func(fi *FileInfo) bool {
+		return fi.Name == name
+	})
+	return fi, err == nil
+}
+
+func (d Disk) find(match func(*FileInfo) bool) (*FileInfo, error) {
+	w := newDirWalker(d)
+	for {
+		fi, more := w.next()
+		if !more {
+			break
+		}
+		if match(fi) {
+			return fi, nil
+		}
+	}
+	if w.err != nil {
+		return nil, w.err
+	}
+	return nil, ErrNotFound
+}
+
+// FindText looks up a file by a name written as text using the given
+// character set. Returns false if the name cannot be written in PETSCII.
+func (d Disk) FindText(name string, cs petscii.Charset) (*FileInfo, bool) {
+	pname, err := cs.EncodeString(name)
+	if err != nil {
+		return nil, false
+	}
+	return d.Find(pname)
+}
+
+// Create adds a new file to the disk with the given name and type.
+// Contents are stored with the returned Writer and the directory entry
+// is finalized once the Writer is closed. Returns ErrFileExists if a
+// file with that name is already on the disk, ErrSyntax if the name
+// contains wildcards or any of the characters ",=:", and
+// ErrWriteProtect if the disk is write protected.
+func (d Disk) Create(name string, t FileType) (*Writer, error) {
+	if err := d.writeCheck(); err != nil {
+		return nil, err
+	}
+	if len(name) > MaxFilenameLen {
+		name = name[:MaxFilenameLen]
+	}
+	if !validName(name) {
+		return nil, ErrSyntax
+	}
+	if _, exists := d.findExact(name); exists {
+		return nil, ErrFileExists
+	}
+	fi, err := createDirEntry(d)
+	if err != nil {
+		return nil, err
+	}
+	track, sector, ok := freeBlockFirst(d)
+	if !ok {
+		return nil, ErrDiskFull
+	}
+	d.BamWrite(track, sector, false)
+
+	fi.Type = t
+	fi.Name = name
+	fi.Splat = true // Not closed until the writer says so
+	fi.First = Pos{Track: track, Sector: sector}
+	fi.Size = 1
+	writeFileInfo(d, fi)
+
+	w := newWriter(d, track, sector)
+	w.fi = fi
+	return w, nil
+}
+
+// Open returns a Reader for the contents of the first file that matches
+// the pattern. Returns ErrNotFound if there is no such file.
+func (d Disk) Open(name string) (*Reader, error) {
+	fi, err := d.Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	return newReader(d, fi.First.Track, fi.First.Sector), nil
+}
+
+// Rename changes the name of a file. Returns ErrNotFound if there is no
+// file with the old name, ErrFileExists if the new name is already in
+// use, ErrSyntax if either name contains wildcards or any of the
+// characters ",=:", and ErrWriteProtect if the disk is write protected.
+func (d Disk) Rename(oldName string, newName string) error {
+	if err := d.writeCheck(); err != nil {
+		return err
+	}
+	if len(newName) > MaxFilenameLen {
+		newName = newName[:MaxFilenameLen]
+	}
+	if !validName(oldName) || !validName(newName) {
+		return ErrSyntax
+	}
+	fi, ok := d.findExact(oldName)
+	if !ok {
+		return ErrNotFound
+	}
+	if _, exists := d.findExact(newName); exists {
+		return ErrFileExists
+	}
+	e := d.Editor()
+	e.Pos = fi.pos
+	e.Move(5)
+	e.WriteStringN(newName, 0xa0, MaxFilenameLen)
+	return nil
+}
+
+// Relabel changes the disk name and ID found in the header without
+// reformatting the disk.
+func (d Disk) Relabel(name string, id string) {
+	if len(name) > 0xf {
+		name = name[:0xf]
+	}
+	if len(id) > 2 {
+		id = id[:2]
+	}
+	e := d.Editor()
+	e.Seek(e.f.DirTrack, 0, e.f.labelAt)
+	e.WriteStringN(name, 0xa0, 0x10) // Disk Name
+	e.Move(2)                        // Fill
+	e.WriteStringN(id, 0x20, 2)      // Disk ID
 }
 
-func (d Disk) Find(name string) (*FileInfo, bool) {
+// Scratch removes the files that match the pattern from the disk and
+// returns the number of files removed. Locked files are left as-is.
+// Returns ErrSyntax if the pattern cannot be parsed or has no name and
+// ErrWriteProtect if the disk is write protected. If the directory
+// cannot be read to the end, the files found before the problem are
+// removed and the error is returned with the count.
+func (d Disk) Scratch(pattern string) (int, error) {
+	if err := d.writeCheck(); err != nil {
+		return 0, err
+	}
+	p, err := ParsePattern(pattern)
+	if err != nil {
+		return 0, err
+	}
+	if len(p.Names) == 0 {
+		return 0, ErrSyntax
+	}
 	w := newDirWalker(d)
+	n := 0
 	for {
 		fi, more := w.next()
 		if !more {
 			break
 		}
-		if fi.Name == name {
-			return fi, true
+		if !p.Match(fi) || fi.Locked {
+			continue
 		}
+		scratchFile(d, fi)
+		n++
+	}
+	if w.err != nil {
+		return n, w.err
 	}
-	return nil, false
+	return n, nil
 }
 
-func Create(filename string) *Writer {
+// Remove scratches the file with exactly the given name. Wildcards in
+// the name are not expanded. A locked file is left as-is, the same as
+// with Scratch. Returns ErrNotFound if there is no such file and
+// ErrWriteProtect if the disk is write protected.
+func (d Disk) Remove(name string) error {
+	if err := d.writeCheck(); err != nil {
+		return err
+	}
+	if len(name) > MaxFilenameLen {
+		name = name[:MaxFilenameLen]
+	}
+	fi, ok := d.findExact(name)
+	if !ok || fi.Type == Del {
+		return ErrNotFound
+	}
+	if !fi.Locked {
+		scratchFile(d, fi)
+	}
 	return nil
 }
 
+// Free the blocks used by a file and mark its directory entry as
+// deleted.
+func scratchFile(d Disk, fi *FileInfo) {
+	if fi.Type == Cbm {
+		blocks, _ := partitionBlocks(d, fi)
+		for _, pos := range blocks {
+			d.BamWrite(pos.Track, pos.Sector, true)
+		}
+	} else {
+		freeChain(d, fi.First.Track, fi.First.Sector)
+	}
+	if fi.Type == Rel {
+		freeChain(d, fi.SideSector.Track, fi.SideSector.Sector)
+	}
+	fi.Type = Del
+	fi.Splat = true
+	fi.SaveAt = false
+	writeFileInfo(d, fi)
+}
+
+// Release all blocks in the chain that starts at the given track and
+// sector. Stops early if a block is already free, which also prevents
+// following a chain that loops back on itself, or is not on the disk.
+func freeChain(d Disk, track int, sector int) {
+	e := d.Editor()
+	for track != 0 {
+		if d.checkBlock(track, sector) != nil || d.BamRead(track, sector) {
+			return
+		}
+		d.BamWrite(track, sector, true)
+		e.Seek(track, sector, 0)
+		track = e.Read()
+		sector = e.Read()
+	}
+}
+
 // For a given track and sector, compute the location of the BAM entry.
 // This function will move the editor position to the start of the BAM
 // record. It returns the offset from that position to the byte that
 // holds the bitmap and the mask that should be used to modify the entry.
 func bamPos(e *Editor, track int, sector int) (off int, mask int) {
-	bmapOffset := 1
-	bytesPerRecord := 4
-	if track < Flip {
-		e.Seek(DirTrack, 0, 4)
-	} else {
-		e.Seek(BamTrack, 0, 0)
-		track = track - Flip + 1
-		bmapOffset = 0
-		bytesPerRecord = 3
-	}
-	e.Move((track - 1) * bytesPerRecord)
+	rec, bmapOffset, _ := e.f.bamEntry(e.f, track)
+	e.Pos = rec
 	off = sector/8 + bmapOffset
 	mask = 1 << byte(sector%8)
 	return off, mask
 }
 
 // BamRead returns true if the given track and sector is marked as free
-// in the block availability map. Otherwise returns false.
+// in the block availability map. Otherwise returns false, which includes
+// blocks that are not on the disk.
 func (d Disk) BamRead(track int, sector int) bool {
+	if d.checkBlock(track, sector) != nil {
+		return false
+	}
 	e := d.Editor()
 	off, mask := bamPos(e, track, sector)
 	bmap := e.Move(off).Peek()
@@ -343,8 +594,12 @@ func (d Disk) BamRead(track int, sector int) bool {
 }
 
 // BamWrite updates the block availability map for the given track and
-// sector. True markes it as free, false as allocated.
+// sector. True markes it as free, false as allocated. Blocks that are not
+// on the disk are ignored.
 func (d Disk) BamWrite(track int, sector int, val bool) {
+	if d.checkBlock(track, sector) != nil {
+		return
+	}
 	// Do nothing if the value is the same
 	prev := d.BamRead(track, sector)
 	if prev == val {
@@ -360,12 +615,6 @@ func (d Disk) BamWrite(track int, sector int, val bool) {
 	e := d.Editor()
 	off, mask := bamPos(e, track, sector)
 
-	// Update the number of available sectors for this track if on the
-	// front side
-	if track < Flip {
-		e.Poke(e.Peek() + delta)
-	}
-
 	// Update the bitmap entry
 	bmap := e.Move(off).Peek()
 	if val {
@@ -375,11 +624,7 @@ func (d Disk) BamWrite(track int, sector int, val bool) {
 	}
 	e.Poke(bmap)
 
-	// If the track was on the back side of the disk, we need to update
-	// the supplemental sector free count on the from side BAM sector
-	if track >= Flip {
-		e.Seek(DirTrack, 0, 0xdd)
-		off = track - Flip
-		e.Move(off).Poke(e.Peek() + delta)
-	}
+	// Update the number of available sectors for this track
+	freeCountPos(e, track)
+	e.Poke(e.Peek() + delta)
 }
@@ -1,9 +1,11 @@
 package d71
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/blackchip-org/vt128/binary"
+	"github.com/blackchip-org/vt128/petscii"
 )
 
 func TestNewDisk(t *testing.T) {
@@ -212,6 +214,30 @@ func TestTrackInfoBack(t *testing.T) {
 	}
 }
 
+func TestTrackInfoOffDisk(t *testing.T) {
+	d := NewDisk("", "")
+	for _, track := range []int{0, 99} {
+		if ti := d.TrackInfo(track); ti != (TrackInfo{}) {
+			t.Errorf("%v: wanted empty track info ; got %+v", track, ti)
+		}
+	}
+}
+
+func TestBamOffDisk(t *testing.T) {
+	d := NewDisk("", "")
+	before := append(Disk{}, d...)
+	for _, pos := range []Pos{{Track: 0}, {Track: 1, Sector: 21}, {Track: 99}, {Track: -1, Sector: -1}} {
+		if d.BamRead(pos.Track, pos.Sector) {
+			t.Errorf("%v: wanted not free", pos)
+		}
+		d.BamWrite(pos.Track, pos.Sector, true)
+		d.BamWrite(pos.Track, pos.Sector, false)
+	}
+	if !bytes.Equal(before, d) {
+		t.Errorf("wanted disk to be unchanged")
+	}
+}
+
 func TestList(t *testing.T) {
 	d := NewDisk("", "")
 	dump := `
@@ -283,3 +309,197 @@ func TestFindNotFound(t *testing.T) {
 		t.Fatalf("wanted not found ; got found")
 	}
 }
+
+func TestFindText(t *testing.T) {
+	d := NewDisk("", "")
+	writeFile(t, d, "HI\xd3", []byte{1})
+	tests := []struct {
+		cs   petscii.Charset
+		name string
+	}{
+		{petscii.Upper, "hi♥"},
+		{petscii.Lower, "hiS"},
+	}
+	for _, test := range tests {
+		if _, found := d.FindText(test.name, test.cs); !found {
+			t.Errorf("%v: %v not found", test.cs, test.name)
+		}
+	}
+	if _, found := d.FindText("hi♥", petscii.Lower); found {
+		t.Errorf("wanted not found ; got found")
+	}
+}
+
+func writeFile(t *testing.T, d Disk, name string, data []byte) {
+	w, err := d.Create(name, Prg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestScratch(t *testing.T) {
+	d := NewDisk("", "")
+	writeFile(t, d, "FILE 1", make([]byte, 1000))
+	writeFile(t, d, "FILE 2", make([]byte, 10))
+	n, err := d.Scratch("FILE 1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("wanted 1 file scratched ; got %v", n)
+	}
+	if _, found := d.Find("FILE 1"); found {
+		t.Errorf("wanted file to be removed")
+	}
+	if _, found := d.Find("FILE 2"); !found {
+		t.Errorf("wanted other file to remain")
+	}
+	want := 1328 - 1
+	got := d.Info().Free
+	if want != got {
+		t.Errorf("wanted %v free ; got %v", want, got)
+	}
+}
+
+func TestScratchLocked(t *testing.T) {
+	d := NewDisk("", "")
+	writeFile(t, d, "FILE 1", make([]byte, 10))
+	fi, _ := d.Find("FILE 1")
+	fi.Locked = true
+	writeFileInfo(d, fi)
+
+	n, _ := d.Scratch("FILE 1")
+	if n != 0 {
+		t.Errorf("wanted 0 files scratched ; got %v", n)
+	}
+	if _, found := d.Find("FILE 1"); !found {
+		t.Errorf("wanted locked file to remain")
+	}
+}
+
+func TestScratchReuseEntry(t *testing.T) {
+	d := NewDisk("", "")
+	writeFile(t, d, "FILE 1", make([]byte, 10))
+	writeFile(t, d, "FILE 2", make([]byte, 10))
+	d.Scratch("FILE 1")
+	writeFile(t, d, "FILE 3", make([]byte, 10))
+	want := "FILE 3"
+	got := d.List()[0].Name
+	if want != got {
+		t.Errorf("wanted %v ; got %v", want, got)
+	}
+}
+
+func TestRename(t *testing.T) {
+	d := NewDisk("", "")
+	writeFile(t, d, "OLD NAME", []byte{1, 2, 3})
+	if err := d.Rename("OLD NAME", "NEW"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, found := d.Find("OLD NAME"); found {
+		t.Errorf("wanted old name to be gone")
+	}
+	fi, found := d.Find("NEW")
+	if !found {
+		t.Fatalf("wanted new name to be found")
+	}
+	if fi.Type != Prg || fi.Size != 1 {
+		t.Errorf("wanted entry to be unchanged ; got %+v", fi)
+	}
+}
+
+func TestRenameNotFound(t *testing.T) {
+	d := NewDisk("", "")
+	if err := d.Rename("OLD NAME", "NEW"); err != ErrNotFound {
+		t.Fatalf("wanted %v ; got %v", ErrNotFound, err)
+	}
+}
+
+func TestRenameExists(t *testing.T) {
+	d := NewDisk("", "")
+	writeFile(t, d, "FILE 1", []byte{1})
+	writeFile(t, d, "FILE 2", []byte{2})
+	if err := d.Rename("FILE 1", "FILE 2"); err != ErrFileExists {
+		t.Fatalf("wanted %v ; got %v", ErrFileExists, err)
+	}
+}
+
+func TestRelabel(t *testing.T) {
+	d := NewDisk("OLD", "AB")
+	d.Relabel("NEW", "CD")
+	info := d.Info()
+	if info.Name != "NEW" {
+		t.Errorf("wanted NEW ; got %v", info.Name)
+	}
+	if info.ID != "CD" {
+		t.Errorf("wanted CD ; got %v", info.ID)
+	}
+	if info.DosType != "2A" {
+		t.Errorf("wanted 2A ; got %v", info.DosType)
+	}
+}
+
+func TestPosValid(t *testing.T) {
+	tests := []struct {
+		pos  Pos
+		want bool
+	}{
+		{Pos{1, 0, 0}, true},
+		{Pos{70, 16, 255}, true},
+		{Pos{0, 0, 0}, false},
+		{Pos{71, 0, 0}, false},
+		{Pos{1, 21, 0}, false},
+		{Pos{18, 19, 0}, false},
+		{Pos{1, -1, 0}, false},
+		{Pos{1, 0, 256}, false},
+	}
+	for _, test := range tests {
+		if got := test.pos.Valid(); got != test.want {
+			t.Errorf("%v: wanted %v ; got %v", test.pos, test.want, got)
+		}
+	}
+	if D64.Valid(Pos{Track: 36}) {
+		t.Errorf("track 36 should not be valid for D64")
+	}
+}
+
+func TestPosMoveOffDisk(t *testing.T) {
+	p := Pos{Track: 70, Sector: 16, At: 255}
+	p.Move(SectorLen * 2)
+	if p.Valid() {
+		t.Errorf("wanted invalid position ; got %v", p)
+	}
+	p = Pos{Track: 1, Sector: 0, At: 0}
+	p.Move(-SectorLen * 2)
+	if p.Valid() {
+		t.Errorf("wanted invalid position ; got %v", p)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	d := NewDisk("", "")
+	writeFile(t, d, "AB", []byte{1})
+	writeFile(t, d, "AXE", []byte{1})
+	free := d.Info().Free
+	if err := d.Remove("A*"); err != ErrNotFound {
+		t.Errorf("wanted %v ; got %v", ErrNotFound, err)
+	}
+	if err := d.Remove("AB"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := fileNames(d.List("*=P")); got != "AXE" {
+		t.Errorf("unexpected files: %v", got)
+	}
+	if d.Info().Free != free+1 {
+		t.Errorf("wanted %v free ; got %v", free+1, d.Info().Free)
+	}
+	if err := d.Remove("AB"); err != ErrNotFound {
+		t.Errorf("wanted %v ; got %v", ErrNotFound, err)
+	}
+}
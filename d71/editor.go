@@ -2,17 +2,22 @@ package d71
 
 import "bytes"
 
+// Editor reads and writes bytes on the disk at a position that moves
+// forward as bytes are read or written. If the position is not on the
+// disk, reads return zero, writes are ignored, and the position stays
+// where it is. Use Valid to check the position.
 type Editor struct {
 	disk Disk
+	f    *Format
 	Pos  Pos
 }
 
 func (e *Editor) Mark() *Editor {
-	return &Editor{disk: e.disk, Pos: e.Pos}
+	return &Editor{disk: e.disk, f: e.f, Pos: e.Pos}
 }
 
 func (e *Editor) Move(delta int) *Editor {
-	e.Pos.Move(delta)
+	e.Pos.move(e.f.Geom, delta)
 	return e
 }
 
@@ -20,22 +25,41 @@ func (e *Editor) Seek(track int, sector int, at int) {
 	e.Pos.Seek(track, sector, at)
 }
 
+// Valid returns true if the position is on the disk.
+func (e *Editor) Valid() bool {
+	return e.offset() >= 0
+}
+
 func (e *Editor) Peek() int {
-	return int(e.disk[e.Pos.Offset()])
+	off := e.offset()
+	if off < 0 {
+		return 0
+	}
+	return int(e.disk[off])
 }
 
 func (e *Editor) Poke(val int) {
-	e.disk[e.Pos.Offset()] = byte(val)
+	if off := e.offset(); off >= 0 {
+		e.disk[off] = byte(val)
+	}
 }
 
 func (e *Editor) Write(val int) {
-	e.disk[e.Pos.Offset()] = byte(val)
-	e.Pos.Move(1)
+	off := e.offset()
+	if off < 0 {
+		return
+	}
+	e.disk[off] = byte(val)
+	e.Pos.move(e.f.Geom, 1)
 }
 
 func (e *Editor) Read() int {
-	v := int(e.disk[e.Pos.Offset()])
-	e.Pos.Move(1)
+	off := e.offset()
+	if off < 0 {
+		return 0
+	}
+	v := int(e.disk[off])
+	e.Pos.move(e.f.Geom, 1)
 	return v
 }
 
@@ -49,30 +73,23 @@ func (e *Editor) WriteWord(val int) {
 }
 
 func (e *Editor) WriteString(val string) {
-	n := len(val)
-	off := e.Pos.Offset()
-	for i := 0; i < n; i++ {
-		e.disk[off+i] = byte(val[i])
+	for i := 0; i < len(val); i++ {
+		e.Write(int(val[i]))
 	}
-	e.Pos.Move(n)
 }
 
 func (e *Editor) ReadString(length int) string {
 	var buf bytes.Buffer
-	off := e.Pos.Offset()
 	for i := 0; i < length; i++ {
-		buf.WriteByte(e.disk[off+i])
+		buf.WriteByte(byte(e.Read()))
 	}
-	e.Pos.Move(length)
 	return buf.String()
 }
 
 func (e *Editor) Fill(val int, length int) {
-	off := e.Pos.Offset()
 	for i := 0; i < length; i++ {
-		e.disk[off+i] = byte(val)
+		e.Write(val)
 	}
-	e.Pos.Move(length)
 }
 
 func (e *Editor) WriteStringN(val string, pad int, length int) {
@@ -97,3 +114,13 @@ func (e *Editor) Sector() int {
 func (e *Editor) At() int {
 	return e.Pos.At
 }
+
+// Returns the offset of the position in the image, or -1 if the
+// position is not on the disk or past the end of the image.
+func (e *Editor) offset() int {
+	off := e.f.Offset(e.Pos.Track, e.Pos.Sector, e.Pos.At)
+	if off >= len(e.disk) {
+		return -1
+	}
+	return off
+}
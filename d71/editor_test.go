@@ -37,3 +37,50 @@ func TestWriteWord(t *testing.T) {
 		t.Errorf("expected %x ; actual %x", expected2, actual2)
 	}
 }
+
+func TestEditorOffDisk(t *testing.T) {
+	d := NewDisk("", "")
+	e := d.Editor()
+	e.Seek(99, 0, 0)
+	if e.Valid() {
+		t.Errorf("wanted position to be invalid")
+	}
+	if v := e.Read(); v != 0 {
+		t.Errorf("wanted 0 ; got %v", v)
+	}
+	if v := e.Peek(); v != 0 {
+		t.Errorf("wanted 0 ; got %v", v)
+	}
+	e.Write(1)
+	e.Poke(1)
+	e.Fill(1, 10)
+	e.WriteString("ABC")
+	if s := e.ReadString(3); s != "\x00\x00\x00" {
+		t.Errorf("unexpected string: %q", s)
+	}
+	if e.Track() != 99 || e.Sector() != 0 || e.At() != 0 {
+		t.Errorf("wanted position to stay at 99, 0, 0 ; got %+v", e.Pos)
+	}
+	if off := (&Pos{Track: 99}).Offset(); off != -1 {
+		t.Errorf("wanted -1 ; got %v", off)
+	}
+	if off := Offset(1, 21, 0); off != -1 {
+		t.Errorf("wanted -1 ; got %v", off)
+	}
+	if off := D64.Offset(36, 0, 0); off != -1 {
+		t.Errorf("wanted -1 ; got %v", off)
+	}
+}
+
+func TestEditorEndOfImage(t *testing.T) {
+	d := NewDisk("", "")[:Offset(2, 0, 0)]
+	e := d.Editor()
+	e.Seek(1, 20, SectorLen-2)
+	if s := e.ReadString(4); s != "\x00\x00\x00\x00" {
+		t.Errorf("unexpected string: %q", s)
+	}
+	if e.Valid() || e.Track() != 2 || e.Sector() != 0 {
+		t.Errorf("wanted to stop at the end of the image ; got %+v", e.Pos)
+	}
+	e.Write(1)
+}
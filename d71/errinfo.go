@@ -0,0 +1,114 @@
+package d71
+
+// Codes found in the error table appended to some disk images. Each
+// block on the disk has a one byte entry in the table.
+const (
+	ErrCodeNone         = 0x00 // No error recorded
+	ErrCodeOK           = 0x01 // No error
+	ErrCodeNoHeader     = 0x02 // 20, header block not found
+	ErrCodeNoSync       = 0x03 // 21, no sync character
+	ErrCodeNoData       = 0x04 // 22, data block not present
+	ErrCodeDataChecksum = 0x05 // 23, checksum error in data block
+	ErrCodeDecode       = 0x06 // 24, byte decoding error
+	ErrCodeVerify       = 0x07 // 25, write verify error
+	ErrCodeWriteProtect = 0x08 // 26, write protect on
+	ErrCodeHeadChecksum = 0x09 // 27, checksum error in header block
+	ErrCodeLongData     = 0x0a // 28, long data block
+	ErrCodeIDMismatch   = 0x0b // 29, disk ID mismatch
+	ErrCodeNotReady     = 0x0f // 74, drive not ready
+	ErrCodeGCRDecode    = 0x10 // 24, GCR decoding error
+)
+
+var dosErrorCodes = map[int]int{
+	ErrCodeNoHeader:     20,
+	ErrCodeNoSync:       21,
+	ErrCodeNoData:       22,
+	ErrCodeDataChecksum: 23,
+	ErrCodeDecode:       24,
+	ErrCodeVerify:       25,
+	ErrCodeWriteProtect: 26,
+	ErrCodeHeadChecksum: 27,
+	ErrCodeLongData:     28,
+	ErrCodeIDMismatch:   29,
+	ErrCodeNotReady:     74,
+	ErrCodeGCRDecode:    24,
+}
+
+// HasErrorTable returns true if the disk image includes an error table.
+func (d Disk) HasErrorTable() bool {
+	f := d.Format()
+	return len(d) == f.Len+f.Blocks()
+}
+
+// WithErrorTable returns the disk with an error table appended where
+// each block is marked as having no error. If the disk already has a
+// table, the disk is returned as-is.
+func (d Disk) WithErrorTable() Disk {
+	if d.HasErrorTable() {
+		return d
+	}
+	f := d.Format()
+	nd := make(Disk, f.Len+f.Blocks())
+	copy(nd, d)
+	for i := f.Len; i < len(nd); i++ {
+		nd[i] = ErrCodeOK
+	}
+	return nd
+}
+
+// Offset of the error table entry for a block
+func errorTableOffset(f *Format, track int, sector int) int {
+	return f.Len + f.Geom[track].Offset/SectorLen + sector
+}
+
+// BlockError returns the error table code for the given block.
+// ErrCodeOK is returned if the disk does not have an error table or the
+// block is not on the disk.
+func (d Disk) BlockError(track int, sector int) int {
+	f := d.Format()
+	if !d.HasErrorTable() || !f.Valid(Pos{Track: track, Sector: sector}) {
+		return ErrCodeOK
+	}
+	return int(d[errorTableOffset(f, track, sector)])
+}
+
+// SetBlockError changes the error table code for the given block.
+// Returns ErrNoErrorTable if the disk does not have an error table, use
+// WithErrorTable to add one first, and ErrBadLink if the block is not on
+// the disk.
+func (d Disk) SetBlockError(track int, sector int, code int) error {
+	if !d.HasErrorTable() {
+		return ErrNoErrorTable
+	}
+	if err := d.checkBlock(track, sector); err != nil {
+		return err
+	}
+	d[errorTableOffset(d.Format(), track, sector)] = byte(code)
+	return nil
+}
+
+// Returns a DOSError if the block is flagged in the error table with an
+// error that prevents it from being read.
+func (d Disk) readCheck(track int, sector int) error {
+	code := d.BlockError(track, sector)
+	switch code {
+	case ErrCodeNone, ErrCodeOK, ErrCodeVerify, ErrCodeWriteProtect:
+		return nil
+	}
+	dosCode, ok := dosErrorCodes[code]
+	if !ok {
+		return nil
+	}
+	return newDOSError(dosCode, track, sector)
+}
+
+// Returns ErrWriteProtect if the header block is flagged in the error
+// table as write protected. The whole disk is treated as write protected
+// in the same way the drive treats a disk with the notch covered.
+func (d Disk) writeCheck() error {
+	f := d.Format()
+	if d.BlockError(f.DirTrack, 0) == ErrCodeWriteProtect {
+		return newDOSError(26, f.DirTrack, 0)
+	}
+	return nil
+}
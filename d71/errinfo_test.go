@@ -0,0 +1,151 @@
+package d71
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestErrorTableImport(t *testing.T) {
+	d := NewDisk("ERRORS", "").WithErrorTable()
+	if len(d) != 351062 {
+		t.Fatalf("wanted 351062 bytes ; got %v", len(d))
+	}
+	d.SetBlockError(18, 4, ErrCodeDataChecksum)
+
+	dir, err := ioutil.TempDir("", "d71")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "errors.d71")
+	if err := d.Export(filename); err != nil {
+		t.Fatal(err)
+	}
+	d2, err := Import(filename)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !d2.HasErrorTable() {
+		t.Fatalf("wanted error table")
+	}
+	if d2.Format() != D71 {
+		t.Errorf("wanted D71 format")
+	}
+	if name := d2.Info().Name; name != "ERRORS" {
+		t.Errorf("wanted ERRORS ; got %v", name)
+	}
+	if code := d2.BlockError(18, 4); code != ErrCodeDataChecksum {
+		t.Errorf("wanted %v ; got %v", ErrCodeDataChecksum, code)
+	}
+	if code := d2.BlockError(18, 5); code != ErrCodeOK {
+		t.Errorf("wanted %v ; got %v", ErrCodeOK, code)
+	}
+}
+
+func TestErrorTableOffset(t *testing.T) {
+	d := NewDisk("", "").WithErrorTable()
+	d.SetBlockError(1, 0, ErrCodeNoSync)
+	d.SetBlockError(70, 16, ErrCodeNoHeader)
+	if d[DiskLen] != ErrCodeNoSync {
+		t.Errorf("wanted first entry %v ; got %v", ErrCodeNoSync, d[DiskLen])
+	}
+	if d[len(d)-1] != ErrCodeNoHeader {
+		t.Errorf("wanted last entry %v ; got %v", ErrCodeNoHeader, d[len(d)-1])
+	}
+}
+
+func TestErrorTableNone(t *testing.T) {
+	d := NewDisk("", "")
+	if d.HasErrorTable() {
+		t.Fatalf("wanted no error table")
+	}
+	if code := d.BlockError(18, 0); code != ErrCodeOK {
+		t.Errorf("wanted %v ; got %v", ErrCodeOK, code)
+	}
+	if err := d.SetBlockError(18, 0, ErrCodeNoSync); err != ErrNoErrorTable {
+		t.Errorf("wanted %v ; got %v", ErrNoErrorTable, err)
+	}
+}
+
+func TestErrorTableBadBlock(t *testing.T) {
+	d := NewDisk("", "").WithErrorTable()
+	if err := d.SetBlockError(99, 0, ErrCodeNoSync); !errors.Is(err, ErrBadLink) {
+		t.Errorf("wanted %v ; got %v", ErrBadLink, err)
+	}
+	if code := d.BlockError(99, 0); code != ErrCodeOK {
+		t.Errorf("wanted %v ; got %v", ErrCodeOK, code)
+	}
+}
+
+func TestReaderError(t *testing.T) {
+	d := NewDisk("", "").WithErrorTable()
+	data := make([]byte, 300)
+	writeFile(t, d, "FILE", data)
+	fi, _ := d.Find("FILE")
+	e := d.Editor()
+	e.Seek(fi.First.Track, fi.First.Sector, 0)
+	next := Pos{Track: e.Read(), Sector: e.Read()}
+	d.SetBlockError(next.Track, next.Sector, ErrCodeDataChecksum)
+
+	r, err := d.Open("FILE")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	n, err := ioutil.ReadAll(r)
+	if len(n) != blockDataLen {
+		t.Errorf("wanted %v bytes ; got %v", blockDataLen, len(n))
+	}
+	var re *DOSError
+	if !errors.As(err, &re) {
+		t.Fatalf("wanted read error ; got %v", err)
+	}
+	if re.Code != 23 || re.Track != next.Track || re.Sector != next.Sector {
+		t.Errorf("unexpected error: %+v", re)
+	}
+	want := "23,READ ERROR,17,06"
+	if re.Error() != want {
+		t.Errorf("wanted %v ; got %v", want, re.Error())
+	}
+}
+
+func TestReaderErrorIgnored(t *testing.T) {
+	d := NewDisk("", "").WithErrorTable()
+	writeFile(t, d, "FILE", []byte{1, 2, 3})
+	fi, _ := d.Find("FILE")
+	d.SetBlockError(fi.First.Track, fi.First.Sector, ErrCodeWriteProtect)
+	r, _ := d.Open("FILE")
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(data) != 3 {
+		t.Errorf("wanted 3 bytes ; got %v", len(data))
+	}
+}
+
+func TestWriteProtect(t *testing.T) {
+	d := NewDisk("", "").WithErrorTable()
+	writeFile(t, d, "FILE", []byte{1})
+	d.SetBlockError(18, 0, ErrCodeWriteProtect)
+
+	_, err := d.Create("NEW", Prg)
+	if !errors.Is(err, ErrWriteProtect) {
+		t.Fatalf("wanted %v ; got %v", ErrWriteProtect, err)
+	}
+	want := "26,WRITE PROTECT ON,18,00"
+	if err.Error() != want {
+		t.Errorf("wanted %v ; got %v", want, err)
+	}
+	if _, err := d.Scratch("FILE"); !errors.Is(err, ErrWriteProtect) {
+		t.Errorf("wanted %v ; got %v", ErrWriteProtect, err)
+	}
+	if err := d.Rename("FILE", "NEW"); !errors.Is(err, ErrWriteProtect) {
+		t.Errorf("wanted %v ; got %v", ErrWriteProtect, err)
+	}
+	if _, err := d.Open("FILE"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
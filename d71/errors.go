@@ -1,9 +1,128 @@
 package d71
 
-import "fmt"
+import (
+	"fmt"
+	"io/fs"
+)
+
+// Errors returned by disk operations. Each is reported with the same
+// number and message that the drive puts on its error channel.
+// ErrDirFull and ErrRecordLen share a number with ErrDiskFull and
+// ErrSyntax but only match themselves.
+var (
+	ErrBadLink      = newDOSError(66, 0, 0)
+	ErrClosed       = newDOSError(61, 0, 0)
+	ErrDiskFull     = newDOSError(72, 0, 0)
+	ErrDirFull      = exactDOSError(72)
+	ErrFileExists   = newDOSError(63, 0, 0)
+	ErrFileType     = newDOSError(64, 0, 0)
+	ErrNoBlock      = newDOSError(65, 0, 0)
+	ErrNoRecord     = newDOSError(50, 0, 0)
+	ErrNotFound     = newDOSError(62, 0, 0)
+	ErrNotSubdir    = newDOSError(77, 0, 0)
+	ErrOverflow     = newDOSError(51, 0, 0)
+	ErrRecordLen    = exactDOSError(30)
+	ErrSyntax       = newDOSError(30, 0, 0)
+	ErrTooLarge     = newDOSError(52, 0, 0)
+	ErrWriteProtect = newDOSError(26, 0, 0)
+)
 
+// Damaged block chains have no error of their own in DOS. These are
+// reported in a Problem, which the drive reports as ILLEGAL TRACK OR
+// SECTOR at the block where the problem was found. See
+// Problem.DOSError.
 var (
-	ErrDiskFull   = fmt.Errorf("disk full")
-	ErrDirFull    = fmt.Errorf("directory full")
-	ErrFileExists = fmt.Errorf("file exists")
+	ErrChainLoop = fmt.Errorf("block chain loops")
+	ErrCrossLink = fmt.Errorf("block is cross-linked")
 )
+
+// ErrNoErrorTable is returned when changing the error table of a disk
+// image that does not have one. There is no error table on a real disk
+// so DOS has no error for this.
+var ErrNoErrorTable = fmt.Errorf("disk has no error table")
+
+// Messages for each DOS error number. The messages for numbers below
+// 20 start with a space in the same way as they do on the drive.
+var dosMessages = map[int]string{
+	0:  " OK",
+	1:  " FILES SCRATCHED",
+	20: "READ ERROR",
+	21: "READ ERROR",
+	22: "READ ERROR",
+	23: "READ ERROR",
+	24: "READ ERROR",
+	25: "WRITE ERROR",
+	26: "WRITE PROTECT ON",
+	27: "READ ERROR",
+	28: "WRITE ERROR",
+	29: "DISK ID MISMATCH",
+	30: "SYNTAX ERROR",
+	31: "SYNTAX ERROR",
+	32: "SYNTAX ERROR",
+	33: "SYNTAX ERROR",
+	34: "SYNTAX ERROR",
+	50: "RECORD NOT PRESENT",
+	51: "OVERFLOW IN RECORD",
+	52: "FILE TOO LARGE",
+	61: "FILE NOT OPEN",
+	62: "FILE NOT FOUND",
+	63: "FILE EXISTS",
+	64: "FILE TYPE MISMATCH",
+	65: "NO BLOCK",
+	66: "ILLEGAL TRACK OR SECTOR",
+	72: "DISK FULL",
+	73: "CBM DOS V3.0 1571",
+	74: "DRIVE NOT READY",
+	77: "SELECTED PARTITION ILLEGAL",
+}
+
+// DOSError is an error as reported on the error channel of the drive: a
+// number, a message, and the track and sector where the error happened.
+// Use errors.Is to check for a DOS error number, such as
+// errors.Is(err, ErrNotFound). FILE NOT FOUND also matches
+// fs.ErrNotExist and FILE EXISTS also matches fs.ErrExist.
+type DOSError struct {
+	Code   int // DOS error number, e.g. 62
+	Msg    string
+	Track  int
+	Sector int
+	exact  bool // Only matches itself with errors.Is
+}
+
+func newDOSError(code int, track int, sector int) *DOSError {
+	msg, ok := dosMessages[code]
+	if !ok {
+		msg = "READ ERROR"
+	}
+	return &DOSError{Code: code, Msg: msg, Track: track, Sector: sector}
+}
+
+// Creates an error that only matches itself and not other errors with
+// the same number.
+func exactDOSError(code int) *DOSError {
+	e := newDOSError(code, 0, 0)
+	e.exact = true
+	return e
+}
+
+func (e *DOSError) Error() string {
+	return fmt.Sprintf("%02d,%v,%02d,%02d", e.Code, e.Msg, e.Track, e.Sector)
+}
+
+// Is returns true if the target is a DOSError with the same number,
+// unless either is ErrDirFull or ErrRecordLen.
+func (e *DOSError) Is(target error) bool {
+	if t, ok := target.(*DOSError); ok {
+		if t.exact || e.exact {
+			return t == e
+		}
+		return t.Code == e.Code
+	}
+	switch e.Code {
+	case 62:
+		return target == fs.ErrNotExist
+	case 63:
+		return target == fs.ErrExist
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package d71
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"testing"
+)
+
+func TestDOSErrorString(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{ErrNotFound, "62,FILE NOT FOUND,00,00"},
+		{ErrFileExists, "63,FILE EXISTS,00,00"},
+		{ErrDiskFull, "72,DISK FULL,00,00"},
+		{ErrWriteProtect, "26,WRITE PROTECT ON,00,00"},
+		{newDOSError(66, 99, 3), "66,ILLEGAL TRACK OR SECTOR,99,03"},
+		{newDOSError(23, 17, 6), "23,READ ERROR,17,06"},
+	}
+	for _, test := range tests {
+		if got := test.err.Error(); got != test.want {
+			t.Errorf("wanted %v ; got %v", test.want, got)
+		}
+	}
+}
+
+func TestDOSErrorIs(t *testing.T) {
+	err := fmt.Errorf("wrapped: %w", newDOSError(66, 40, 0))
+	if !errors.Is(err, ErrBadLink) {
+		t.Errorf("wanted %v ; got %v", ErrBadLink, err)
+	}
+	if errors.Is(err, ErrNotFound) {
+		t.Errorf("did not want %v", ErrNotFound)
+	}
+	var de *DOSError
+	if !errors.As(err, &de) || de.Track != 40 || de.Sector != 0 {
+		t.Errorf("unexpected error: %+v", de)
+	}
+	if !errors.Is(ErrNotFound, fs.ErrNotExist) {
+		t.Errorf("wanted %v to match %v", ErrNotFound, fs.ErrNotExist)
+	}
+	if !errors.Is(ErrFileExists, fs.ErrExist) {
+		t.Errorf("wanted %v to match %v", ErrFileExists, fs.ErrExist)
+	}
+}
+
+func TestDiskErrors(t *testing.T) {
+	d := NewDisk("", "")
+	writeFile(t, d, "FILE", []byte{1})
+	if _, err := d.Open("NOPE"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("wanted %v ; got %v", ErrNotFound, err)
+	}
+	if _, err := d.Create("FILE", Prg); !errors.Is(err, ErrFileExists) {
+		t.Errorf("wanted %v ; got %v", ErrFileExists, err)
+	}
+	if err := d.Rename("NOPE", "OTHER"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("wanted %v ; got %v", fs.ErrNotExist, err)
+	}
+}
+
+func TestDOSErrorSharedCode(t *testing.T) {
+	tests := []struct {
+		err    error
+		target error
+		want   bool
+	}{
+		{ErrSyntax, ErrRecordLen, false},
+		{ErrRecordLen, ErrSyntax, false},
+		{ErrRecordLen, ErrRecordLen, true},
+		{fmt.Errorf("wrapped: %w", ErrRecordLen), ErrRecordLen, true},
+		{ErrDiskFull, ErrDirFull, false},
+		{ErrDirFull, ErrDiskFull, false},
+		{newDOSError(30, 0, 0), ErrSyntax, true},
+	}
+	for _, test := range tests {
+		if got := errors.Is(test.err, test.target); got != test.want {
+			t.Errorf("%v is %v: wanted %v ; got %v", test.err, test.target, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package d71
+
+// Format describes the geometry and the layout of the file system for
+// a type of disk image. The format of a Disk is determined by its size.
+type Format struct {
+	Name        string      // Drive model
+	Len         int         // Image size in bytes without error bytes
+	Geom        []TrackInfo // Track geometry, track zero is unused
+	MaxTrack    int         // Last track on the disk
+	DirTrack    int         // Track with the header, BAM, and directory
+	DirSector   int         // First directory sector
+	DoubleSided bool        // Set if the disk uses both sides
+	DosVersion  int         // DOS version found in the header
+	DosType     string      // DOS type found in the header
+
+	headerSectors  int // Sectors on the directory track before the directory
+	backBamTrack   int // Track reserved for the BAM on the flip side
+	labelAt        int // Offset of the disk name in the header sector
+	labelFill      int // Padding bytes after the DOS type
+	dirInterleave  int
+	fileInterleave int
+	trackOrder     []int // Order in which tracks are used for new files
+	partitions     bool  // Set if partitions can be created on the disk
+
+	// Location of the BAM entry for a track. Returns the position of the
+	// entry, the offset from that position to the bitmap, and the
+	// position of the free sector count.
+	bamEntry func(f *Format, track int) (rec Pos, bmapOffset int, count Pos)
+
+	// Optional, writes the header found at the start of each BAM sector
+	bamHeader func(f *Format, d Disk, id string)
+}
+
+var (
+	// D64 is a 35 track single sided disk used by the 1541
+	D64 *Format
+
+	// D64Ext is a 40 track single sided disk used by the 1541 with the
+	// BAM entries for the extra tracks stored in the SpeedDOS location
+	D64Ext *Format
+
+	// D71 is a 70 track double sided disk used by the 1571
+	D71 *Format
+
+	// D81 is an 80 track disk used by the 1581
+	D81 *Format
+
+	formats []*Format
+)
+
+// Track zones for the 1541 and 1571 drives: the last track of each zone
+// and the number of sectors found in that zone.
+var cbmZones = [][2]int{{17, 21}, {24, 19}, {30, 18}, {40, 17}}
+
+func init() {
+	D71 = &Format{
+		Name:           "1571",
+		Len:            DiskLen,
+		Geom:           Geom,
+		MaxTrack:       MaxTrack,
+		DirTrack:       DirTrack,
+		DirSector:      1,
+		DoubleSided:    true,
+		DosVersion:     0x41,
+		DosType:        "2A",
+		headerSectors:  1,
+		backBamTrack:   BamTrack,
+		labelAt:        0x90,
+		labelFill:      4,
+		dirInterleave:  dirInterleave,
+		fileInterleave: fileInterleave,
+		bamEntry:       bamEntryD71,
+	}
+	D71.trackOrder = trackOrder
+
+	D64 = &Format{
+		Name:           "1541",
+		Geom:           newGeom(35, cbmZones),
+		MaxTrack:       35,
+		DirTrack:       DirTrack,
+		DirSector:      1,
+		DosVersion:     0x41,
+		DosType:        "2A",
+		headerSectors:  1,
+		labelAt:        0x90,
+		labelFill:      4,
+		dirInterleave:  dirInterleave,
+		fileInterleave: 10,
+		bamEntry:       bamEntryD64,
+	}
+	D64.trackOrder = newTrackOrder(D64.DirTrack, 1, D64.MaxTrack)
+
+	ext := *D64
+	D64Ext = &ext
+	D64Ext.Geom = newGeom(40, cbmZones)
+	D64Ext.MaxTrack = 40
+	D64Ext.trackOrder = newTrackOrder(D64Ext.DirTrack, 1, D64Ext.MaxTrack)
+
+	D81 = &Format{
+		Name:           "1581",
+		Geom:           newGeom(80, [][2]int{{80, 40}}),
+		MaxTrack:       80,
+		DirTrack:       40,
+		DirSector:      3,
+		DosVersion:     0x44,
+		DosType:        "3D",
+		headerSectors:  3,
+		labelAt:        0x04,
+		labelFill:      2,
+		dirInterleave:  1,
+		fileInterleave: 1,
+		trackOrder:     newTrackOrder(40, 1, 80),
+		partitions:     true,
+		bamEntry:       bamEntryD81,
+		bamHeader:      bamHeaderD81,
+	}
+
+	formats = []*Format{D71, D64, D64Ext, D81}
+	for _, f := range formats {
+		f.Len = f.Blocks() * SectorLen
+	}
+}
+
+// Create a geometry table for the given number of tracks using the
+// zones provided.
+func newGeom(tracks int, zones [][2]int) []TrackInfo {
+	geom := make([]TrackInfo, tracks+1, tracks+1)
+	offset := 0
+	zone := 0
+	for track := 1; track <= tracks; track++ {
+		for track > zones[zone][0] {
+			zone++
+		}
+		sectors := zones[zone][1]
+		geom[track] = TrackInfo{Sectors: sectors, Offset: offset}
+		offset += sectors * SectorLen
+	}
+	return geom
+}
+
+// Tracks are allocated starting with the ones closest to the center
+// track moving outward and then moving inward.
+func newTrackOrder(center int, first int, last int) []int {
+	order := make([]int, 0, last-first)
+	for track := center - 1; track >= first; track-- {
+		order = append(order, track)
+	}
+	for track := center + 1; track <= last; track++ {
+		order = append(order, track)
+	}
+	return order
+}
+
+func bamEntryD64(f *Format, track int) (Pos, int, Pos) {
+	rec := Pos{Track: f.DirTrack, At: 4 + (track-1)*4}
+	if track > 35 {
+		rec.At = 0xc0 + (track-36)*4
+	}
+	return rec, 1, rec
+}
+
+func bamEntryD71(f *Format, track int) (Pos, int, Pos) {
+	if track < Flip {
+		return bamEntryD64(f, track)
+	}
+	rec := Pos{Track: f.backBamTrack, At: (track - Flip) * 3}
+	count := Pos{Track: f.DirTrack, At: 0xdd + (track - Flip)}
+	return rec, 0, count
+}
+
+func bamEntryD81(f *Format, track int) (Pos, int, Pos) {
+	rec := Pos{Track: f.DirTrack, Sector: 1, At: 0x10 + (track-1)*6}
+	if track > 40 {
+		rec.Sector = 2
+		rec.At = 0x10 + (track-41)*6
+	}
+	return rec, 1, rec
+}
+
+func bamHeaderD81(f *Format, d Disk, id string) {
+	e := f.editor(d)
+	for sector := 1; sector <= 2; sector++ {
+		e.Seek(f.DirTrack, sector, 0)
+		if sector == 1 {
+			e.Write(f.DirTrack) // Link to the second BAM sector
+			e.Write(2)
+		} else {
+			e.Write(0)
+			e.Write(0xff)
+		}
+		e.Write(f.DosVersion)        // DOS version
+		e.Write(f.DosVersion ^ 0xff) // One's complement of the version
+		e.WriteStringN(id, 0x20, 2)  // Disk ID
+		e.Write(0xc0)                // I/O byte: verify on, check header CRC
+		e.Write(0)                   // Auto-boot loader flag
+	}
+}
+
+// Blocks returns the total number of blocks on the disk.
+func (f *Format) Blocks() int {
+	n := 0
+	for track := 1; track <= f.MaxTrack; track++ {
+		n += f.Geom[track].Sectors
+	}
+	return n
+}
+
+// Offset computes the absolute byte offset in the image for a track,
+// sector, and sector offset. Returns -1 if the position is not on a disk
+// with this format.
+func (f *Format) Offset(track int, sector int, at int) int {
+	if !f.Valid(Pos{Track: track, Sector: sector, At: at}) {
+		return -1
+	}
+	return f.Geom[track].Offset + (sector * SectorLen) + at
+}
+
+// Valid returns true if the position can be found on a disk with this
+// format.
+func (f *Format) Valid(p Pos) bool {
+	return p.Track <= f.MaxTrack && p.valid(f.Geom)
+}
+
+// Returns true if the block is reserved for the header, BAM, or the
+// start of the directory.
+func (f *Format) reserved(track int, sector int) bool {
+	if track == f.backBamTrack {
+		return true
+	}
+	return track == f.DirTrack && sector < f.headerSectors
+}
+
+// Returns true if the free sectors on this track are not included in
+// the number of blocks free.
+func (f *Format) system(track int) bool {
+	return track == f.DirTrack || track == f.backBamTrack
+}
+
+// Returns ILLEGAL TRACK OR SECTOR if the block cannot be found in the image.
+func (d Disk) checkBlock(track int, sector int) error {
+	f := d.Format()
+	if !f.Valid(Pos{Track: track, Sector: sector}) || f.Offset(track, sector, 0)+SectorLen > len(d) {
+		return newDOSError(66, track, sector)
+	}
+	return nil
+}
+
+// Format returns the format of the disk image based on its size. Images
+// with an unrecognized size are treated as 1571 disks.
+func (d Disk) Format() *Format {
+	for _, f := range formats {
+		if len(d) == f.Len || len(d) == f.Len+f.Blocks() {
+			return f
+		}
+	}
+	if f := subdirFormatOf(d); f != nil {
+		return f
+	}
+	return D71
+}
+
+// Create an editor for the disk that uses this format
+func (f *Format) editor(d Disk) *Editor {
+	e := &Editor{disk: d, f: f}
+	e.Pos.Track = 1
+	return e
+}
+
+// Returns true if an image with this size has a known format.
+func knownSize(size int) bool {
+	for _, f := range formats {
+		if size == f.Len || size == f.Len+f.Blocks() {
+			return true
+		}
+	}
+	return false
+}
+
+// Create a blank file system on the disk.
+func (f *Format) format(d Disk, name string, id string) {
+	e := f.editor(d)
+
+	e.Seek(f.DirTrack, 0, 0)
+	e.Write(f.DirTrack)   // Track of first directory sector
+	e.Write(f.DirSector)  // Sector of first directory sector
+	e.Write(f.DosVersion) // Disk DOS version type
+	if f.DoubleSided {
+		e.Write(0x80) // Double-sided flag
+	}
+
+	// Mark all sectors as free and then allocate the sectors used by
+	// the file system.
+	for track := 1; track <= f.MaxTrack; track++ {
+		sectors := f.Geom[track].Sectors
+		off, _ := bamPos(e, track, 0)
+		e.Move(off)
+		for i := 0; i < (sectors+7)/8; i++ {
+			bits := sectors - i*8
+			if bits > 8 {
+				bits = 8
+			}
+			e.Write(1<<byte(bits) - 1)
+		}
+		freeCountPos(e, track)
+		e.Poke(sectors)
+	}
+	for track := 1; track <= f.MaxTrack; track++ {
+		for sector := 0; sector < f.Geom[track].Sectors; sector++ {
+			if f.reserved(track, sector) {
+				d.BamWrite(track, sector, false)
+			}
+		}
+	}
+	d.BamWrite(f.DirTrack, f.DirSector, false)
+
+	e.Seek(f.DirTrack, 0, f.labelAt)
+	e.WriteStringN(name, 0xa0, 0x10) // Disk Name
+	e.Fill(0xa0, 2)                  // Fill
+	e.WriteStringN(id, 0x20, 2)      // Disk ID
+	e.Write(0xa0)                    // Fill
+	e.WriteString(f.DosType)         // DOS Type
+	e.Fill(0xa0, f.labelFill)        // Fill
+
+	if f.bamHeader != nil {
+		f.bamHeader(f, d, id)
+	}
+
+	// Blank directory, set link to nothing
+	e.Seek(f.DirTrack, f.DirSector, 1)
+	e.Write(0xff)
+}
@@ -0,0 +1,128 @@
+package d71
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestFormatBySize(t *testing.T) {
+	tests := []struct {
+		size int
+		want *Format
+	}{
+		{349696, D71},
+		{174848, D64},
+		{175531, D64},
+		{196608, D64Ext},
+		{197376, D64Ext},
+	}
+	for _, test := range tests {
+		d := make(Disk, test.size)
+		if got := d.Format(); test.want != got {
+			t.Errorf("size %v: wanted %v ; got %v", test.size, test.want.Name, got.Name)
+		}
+	}
+}
+
+func TestBlankD64(t *testing.T) {
+	d := NewDiskFormat(D64, "BLANK", "")
+	if len(d) != 174848 {
+		t.Fatalf("wanted size 174848 ; got %v", len(d))
+	}
+	e := d.Editor()
+	e.Seek(DirTrack, 0, 0)
+	want := []int{0x12, 0x01, 0x41, 0x00, 0x15, 0xff, 0xff, 0x1f}
+	for i, w := range want {
+		if got := e.Read(); w != got {
+			t.Errorf("byte %v: wanted $%02x ; got $%02x", i, w, got)
+		}
+	}
+	e.Seek(DirTrack, 0, 4+(DirTrack-1)*4)
+	want = []int{0x11, 0xfc, 0xff, 0x07}
+	for i, w := range want {
+		if got := e.Read(); w != got {
+			t.Errorf("track 18 byte %v: wanted $%02x ; got $%02x", i, w, got)
+		}
+	}
+	e.Seek(DirTrack, 0, 0xa5)
+	if got := e.ReadString(2); got != "2A" {
+		t.Errorf("wanted 2A ; got %v", got)
+	}
+	// Nothing should be written to where the 1571 keeps its extra BAM
+	e.Seek(DirTrack, 0, 0xdd)
+	if got := e.Read(); got != 0 {
+		t.Errorf("wanted $00 ; got $%02x", got)
+	}
+	info := d.Info()
+	if info.Free != 664 {
+		t.Errorf("wanted 664 free ; got %v", info.Free)
+	}
+	if info.Name != "BLANK" || info.DoubleSided {
+		t.Errorf("unexpected info: %+v", info)
+	}
+}
+
+func TestBlankD64Ext(t *testing.T) {
+	d := NewDiskFormat(D64Ext, "", "")
+	e := d.Editor()
+	e.Seek(DirTrack, 0, 0xc0)
+	want := []int{0x11, 0xff, 0xff, 0x01}
+	for i, w := range want {
+		if got := e.Read(); w != got {
+			t.Errorf("byte %v: wanted $%02x ; got $%02x", i, w, got)
+		}
+	}
+	if free := d.Info().Free; free != 749 {
+		t.Errorf("wanted 749 free ; got %v", free)
+	}
+	d.BamWrite(40, 16, false)
+	if free := d.TrackInfo(40).Free; free != 16 {
+		t.Errorf("wanted 16 free ; got %v", free)
+	}
+}
+
+func TestD64File(t *testing.T) {
+	d := NewDiskFormat(D64, "", "")
+	data := make([]byte, 600)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	writeFile(t, d, "FILE", data)
+
+	fi, _ := d.Find("FILE")
+	if fi.Size != 3 {
+		t.Errorf("wanted size 3 ; got %v", fi.Size)
+	}
+	e := d.Editor()
+	e.Seek(fi.First.Track, fi.First.Sector, 0)
+	next := Pos{Track: e.Read(), Sector: e.Read()}
+	want := Pos{Track: 17, Sector: 10}
+	if want != next {
+		t.Errorf("wanted next block %+v ; got %+v", want, next)
+	}
+
+	r, _ := d.Open("FILE")
+	got, _ := ioutil.ReadAll(r)
+	if !bytes.Equal(data, got) {
+		t.Errorf("data mismatch")
+	}
+	if free := d.Info().Free; free != 664-3 {
+		t.Errorf("wanted %v free ; got %v", 664-3, free)
+	}
+	if r := d.Validate(); r.Changed() || len(r.Problems) > 0 {
+		t.Errorf("wanted valid disk ; got %+v", r)
+	}
+}
+
+func TestD64ErrorBytes(t *testing.T) {
+	blank := NewDiskFormat(D64, "ERRORS", "")
+	d := make(Disk, len(blank)+D64.Blocks())
+	copy(d, blank)
+	if d.Format() != D64 {
+		t.Fatalf("wanted D64 format")
+	}
+	if name := d.Info().Name; name != "ERRORS" {
+		t.Errorf("wanted ERRORS ; got %v", name)
+	}
+}
@@ -0,0 +1,330 @@
+package d71
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FS presents the files on a disk as a read-only fs.FS. Files on a disk
+// are found in the root directory. On a 1581 disk, partitions that are
+// formatted as subdirectories are presented as directories.
+//
+// File names on a disk are PETSCII strings that may contain bytes that
+// are not valid in a path. Names are mapped to paths by leaving the
+// printable ASCII characters from $20 to $7e as-is and by escaping all
+// other bytes as a percent sign followed by two uppercase hex digits.
+// The percent sign and slash are always escaped as %25 and %2F. A name
+// made up of only "." or ".." has each dot escaped as %2E. Use PathName
+// and FileName to convert between the two.
+//
+// The Sys method of each fs.FileInfo returns the *FileInfo of the
+// directory entry which provides the CBM file type, the size in blocks,
+// and the locked and splat flags. Sys returns nil for the root
+// directory.
+type FS struct {
+	d Disk
+}
+
+// NewFS returns a file system for the contents of the disk.
+func NewFS(d Disk) *FS {
+	return &FS{d: d}
+}
+
+// PathName returns the path element used for a file name on the disk.
+func PathName(name string) string {
+	if name == "." || name == ".." {
+		return strings.Repeat("%2E", len(name))
+	}
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		ch := name[i]
+		if ch < 0x20 || ch > 0x7e || ch == '%' || ch == '/' {
+			fmt.Fprintf(&b, "%%%02X", ch)
+		} else {
+			b.WriteByte(ch)
+		}
+	}
+	return b.String()
+}
+
+// FileName returns the file name on the disk for a path element. Only
+// the path element produced by PathName is accepted so that each file
+// has a single path. Returns fs.ErrInvalid if an escape sequence is
+// malformed, uses lowercase hex digits, or escapes a character that
+// PathName leaves as-is.
+func FileName(elem string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(elem); i++ {
+		if elem[i] != '%' {
+			b.WriteByte(elem[i])
+			continue
+		}
+		if i+2 >= len(elem) {
+			return "", fs.ErrInvalid
+		}
+		ch, err := strconv.ParseUint(elem[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", fs.ErrInvalid
+		}
+		b.WriteByte(byte(ch))
+		i += 2
+	}
+	name := b.String()
+	if PathName(name) != elem {
+		return "", fs.ErrInvalid
+	}
+	return name, nil
+}
+
+// Open opens the named file or directory.
+func (f *FS) Open(name string) (fs.File, error) {
+	d, fi, err := f.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	info := newFsInfo(d, fi, name)
+	if info.IsDir() {
+		sub := d
+		if fi != nil {
+			sub, _ = d.Subdir(fi.Name)
+		}
+		return &fsDir{d: sub, info: info, path: name}, nil
+	}
+	data, err := readContents(d, fi)
+	return &fsFile{info: info, r: bytes.NewReader(data), err: err}, nil
+}
+
+// Stat returns information about the named file or directory.
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	d, fi, err := f.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return newFsInfo(d, fi, name), nil
+}
+
+// ReadDir returns the entries in the named directory sorted by name.
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	d, fi, err := f.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if fi != nil {
+		if d, err = d.Subdir(fi.Name); err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+		}
+	}
+	list, err := readDirEntries(d)
+	if err != nil {
+		return list, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	return list, nil
+}
+
+// Find the disk that holds the named file and the directory entry for
+// that file. The directory entry is nil for the root directory.
+func (f *FS) lookup(op string, name string) (Disk, *FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return f.d, nil, nil
+	}
+	d := f.d
+	elems := strings.Split(name, "/")
+	for i, elem := range elems {
+		fileName, err := FileName(elem)
+		if err != nil {
+			return nil, nil, &fs.PathError{Op: op, Path: name, Err: err}
+		}
+		fi, ok := d.findExact(fileName)
+		if !ok || fi.Type == Del {
+			return nil, nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		if i == len(elems)-1 {
+			return d, fi, nil
+		}
+		if d, err = d.Subdir(fileName); err != nil {
+			return nil, nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+	}
+	panic("unreachable")
+}
+
+// Returns the entries that could be read and an error if the whole
+// directory could not be read.
+func readDirEntries(d Disk) ([]fs.DirEntry, error) {
+	var list []fs.DirEntry
+	files, err := d.Files()
+	for _, fi := range files {
+		if fi.Type == Del || fi.Name == "" {
+			continue
+		}
+		info := newFsInfo(d, fi, PathName(fi.Name))
+		list = append(list, fs.FileInfoToDirEntry(info))
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name() < list[j].Name()
+	})
+	return list, err
+}
+
+// Returns the contents of a file. For a partition that is not a
+// subdirectory, the contents are the blocks in the partition.
+func readContents(d Disk, fi *FileInfo) ([]byte, error) {
+	if fi.Type == Cbm {
+		var data []byte
+		f := d.Format()
+		blocks, p := partitionBlocks(d, fi)
+		for _, pos := range blocks {
+			offset := f.Offset(pos.Track, pos.Sector, 0)
+			data = append(data, d[offset:offset+SectorLen]...)
+		}
+		if p != nil {
+			return data, p
+		}
+		return data, nil
+	}
+	var buf bytes.Buffer
+	_, err := io.Copy(&buf, newReader(d, fi.First.Track, fi.First.Sector))
+	return buf.Bytes(), err
+}
+
+// Returns the number of bytes in the file. The size is computed from
+// the length of the block chain and the index of the last byte used in
+// the final block. If the chain is damaged or a block cannot be read,
+// only the bytes that can be read before the problem are counted.
+func contentSize(d Disk, fi *FileInfo) int64 {
+	if fi.Type == Cbm {
+		return int64(fi.Size) * SectorLen
+	}
+	chain, err := d.Chain(fi.First)
+	for i, pos := range chain {
+		if d.readCheck(pos.Track, pos.Sector) != nil {
+			return int64(i * blockDataLen)
+		}
+	}
+	if err != nil || len(chain) == 0 {
+		return int64(len(chain) * blockDataLen)
+	}
+	e := d.Editor()
+	last := chain[len(chain)-1]
+	e.Seek(last.Track, last.Sector, 1)
+	n := e.Read() - 1
+	if n < 0 {
+		n = 0
+	}
+	return int64((len(chain)-1)*blockDataLen + n)
+}
+
+type fsInfo struct {
+	name string
+	size int64
+	dir  bool
+	fi   *FileInfo
+}
+
+func newFsInfo(d Disk, fi *FileInfo, path string) *fsInfo {
+	info := &fsInfo{name: path[strings.LastIndex(path, "/")+1:], fi: fi}
+	if fi == nil {
+		info.dir = true
+		return info
+	}
+	if fi.Type == Cbm {
+		if _, err := d.Subdir(fi.Name); err == nil {
+			info.dir = true
+			return info
+		}
+	}
+	info.size = contentSize(d, fi)
+	return info
+}
+
+func (i *fsInfo) Name() string       { return i.name }
+func (i *fsInfo) Size() int64        { return i.size }
+func (i *fsInfo) ModTime() time.Time { return time.Time{} }
+func (i *fsInfo) IsDir() bool        { return i.dir }
+func (i *fsInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+func (i *fsInfo) Sys() interface{} {
+	if i.fi == nil {
+		return nil
+	}
+	return i.fi
+}
+
+type fsFile struct {
+	info *fsInfo
+	r    *bytes.Reader
+	err  error // Returned instead of io.EOF if the file could not be read
+}
+
+func (f *fsFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *fsFile) Close() error               { return nil }
+
+func (f *fsFile) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	if err == io.EOF && f.err != nil {
+		err = f.err
+	}
+	return n, err
+}
+
+func (f *fsFile) Seek(offset int64, whence int) (int64, error) {
+	return f.r.Seek(offset, whence)
+}
+
+type fsDir struct {
+	d       Disk
+	info    *fsInfo
+	path    string
+	entries []fs.DirEntry
+	read    bool
+	err     error // Returned once all entries are read
+}
+
+func (f *fsDir) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *fsDir) Close() error               { return nil }
+
+func (f *fsDir) Read(p []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: f.path, Err: fs.ErrInvalid}
+}
+
+func (f *fsDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !f.read {
+		var err error
+		f.entries, err = readDirEntries(f.d)
+		if err != nil {
+			f.err = &fs.PathError{Op: "readdir", Path: f.path, Err: err}
+		}
+		f.read = true
+	}
+	if n <= 0 {
+		list := f.entries
+		f.entries = nil
+		return list, f.err
+	}
+	if len(f.entries) == 0 {
+		if f.err != nil {
+			return nil, f.err
+		}
+		return nil, io.EOF
+	}
+	if n > len(f.entries) {
+		n = len(f.entries)
+	}
+	list := f.entries[:n]
+	f.entries = f.entries[n:]
+	return list, nil
+}
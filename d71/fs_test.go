@@ -0,0 +1,148 @@
+package d71
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestPathName(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"HELLO WORLD", "HELLO WORLD"},
+		{"A/B", "A%2FB"},
+		{"100%", "100%25"},
+		{"\x93CLEAR\xc1", "%93CLEAR%C1"},
+		{".", "%2E"},
+		{"..", "%2E%2E"},
+		{"...", "..."},
+	}
+	for _, test := range tests {
+		path := PathName(test.name)
+		if path != test.path {
+			t.Errorf("%q: wanted %q ; got %q", test.name, test.path, path)
+		}
+		if !fs.ValidPath(path) {
+			t.Errorf("%q: not a valid path", path)
+		}
+		name, err := FileName(path)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", path, err)
+		}
+		if name != test.name {
+			t.Errorf("%q: wanted %q ; got %q", path, test.name, name)
+		}
+	}
+}
+
+func TestFileNameInvalid(t *testing.T) {
+	for _, path := range []string{"A%", "A%4", "A%ZZ", "CLEAR%c1", "%41", "A%2f", ".", "%2E."} {
+		if _, err := FileName(path); err != fs.ErrInvalid {
+			t.Errorf("%q: wanted invalid error ; got %v", path, err)
+		}
+	}
+}
+
+func TestFS(t *testing.T) {
+	d := NewDisk("", "")
+	writeFile(t, d, "HELLO", []byte("hello world"))
+	writeFile(t, d, "A/B", []byte{1, 2, 3})
+	writeFile(t, d, "EMPTY", nil)
+	fsys := NewFS(d)
+	if err := fstest.TestFS(fsys, "HELLO", "A%2FB", "EMPTY"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := fs.ReadFile(fsys, "HELLO")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(data) != "hello world" {
+		t.Errorf("unexpected contents: %q", data)
+	}
+}
+
+func TestFSStat(t *testing.T) {
+	d := NewDisk("", "")
+	writeFile(t, d, "FILE", make([]byte, 300))
+	fi, _ := d.Find("FILE")
+	fi.Locked = true
+	writeFileInfo(d, fi)
+
+	info, err := fs.Stat(NewFS(d), "FILE")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.Size() != 300 {
+		t.Errorf("wanted size 300 ; got %v", info.Size())
+	}
+	sys, ok := info.Sys().(*FileInfo)
+	if !ok {
+		t.Fatalf("wanted *FileInfo ; got %T", info.Sys())
+	}
+	if sys.Type != Prg || sys.Size != 2 || !sys.Locked || sys.Splat {
+		t.Errorf("unexpected file info: %+v", sys)
+	}
+}
+
+func TestFSNotExist(t *testing.T) {
+	_, err := NewFS(NewDisk("", "")).Open("NOPE")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("wanted not exist error ; got %v", err)
+	}
+}
+
+func TestFSSubdir(t *testing.T) {
+	d := NewDiskFormat(D81, "", "")
+	d.CreatePartition("PART", Pos{Track: 41}, 120)
+	sub, _ := d.FormatSubdir("PART", "SUB", "01")
+	writeFile(t, sub, "INSIDE", []byte("inside"))
+	d.CreatePartition("RAW", Pos{Track: 50, Sector: 5}, 2)
+	fsys := NewFS(d)
+
+	if err := fstest.TestFS(fsys, "PART/INSIDE", "RAW"); err != nil {
+		t.Fatal(err)
+	}
+	info, _ := fs.Stat(fsys, "PART")
+	if !info.IsDir() {
+		t.Errorf("wanted directory")
+	}
+	data, _ := fs.ReadFile(fsys, "PART/INSIDE")
+	if string(data) != "inside" {
+		t.Errorf("unexpected contents: %q", data)
+	}
+	raw, _ := fs.ReadFile(fsys, "RAW")
+	want := d[D81.Offset(50, 5, 0):D81.Offset(50, 7, 0)]
+	if !bytes.Equal(raw, want) {
+		t.Errorf("unexpected partition contents")
+	}
+}
+
+func TestContentSize(t *testing.T) {
+	d := NewDisk("", "")
+	for _, n := range []int{0, 1, 253, 254, 255, 508, 1000} {
+		name := fmt.Sprintf("FILE %v", n)
+		writeFile(t, d, name, make([]byte, n))
+		fi, _ := d.Find(name)
+		if size := contentSize(d, fi); size != int64(n) {
+			t.Errorf("wanted %v ; got %v", n, size)
+		}
+	}
+}
+
+func TestContentSizeDamaged(t *testing.T) {
+	d := NewDisk("", "")
+	writeFile(t, d, "FILE", make([]byte, 1000))
+	fi, _ := d.Find("FILE")
+	chain, _ := d.Chain(fi.First)
+	link(d, chain[1], chain[0])
+	data, _ := readContents(d, fi)
+	if size := contentSize(d, fi); size != int64(len(data)) {
+		t.Errorf("wanted %v ; got %v", len(data), size)
+	}
+}
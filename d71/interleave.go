@@ -5,53 +5,39 @@ const (
 	fileInterleave = 6
 )
 
-var trackOrder = make([]int, MaxTrack+1, MaxTrack+1)
-
-func init() {
-	i := 0
-	for track := DirTrack - 1; track >= 1; track-- {
-		trackOrder[i] = track
-		i++
-	}
-	for track := DirTrack + 1; track < Flip; track++ {
-		trackOrder[i] = track
-		i++
-	}
-	for track := BamTrack - 1; track >= Flip; track-- {
-		trackOrder[i] = track
-		i++
-	}
-	for track := BamTrack + 1; track <= MaxTrack; track++ {
-		trackOrder[i] = track
-		i++
-	}
-}
+var trackOrder = append(
+	newTrackOrder(DirTrack, 1, Flip-1),
+	newTrackOrder(BamTrack, Flip, MaxTrack)...,
+)
 
 func freeDirSector(d Disk) (sector int, ok bool) {
-	rem := Geom[DirTrack].Sectors - 1
-	i := 1
+	f := d.Format()
+	sectors := f.Geom[f.DirTrack].Sectors
+	rem := sectors - 1
+	i := f.DirSector
 	for {
-		if d.BamRead(DirTrack, i) {
+		if d.BamRead(f.DirTrack, i) {
 			return i, true
 		}
 		rem--
 		if rem == 0 {
 			return 0, false
 		}
-		i = (i + dirInterleave)
-		if i >= Geom[DirTrack].Sectors {
-			i = (i % Geom[DirTrack].Sectors) + 2
+		i = (i + f.dirInterleave)
+		if i >= sectors {
+			i = (i % sectors) + 2
 		}
 	}
 }
 
 func freeBlockFirst(d Disk) (track int, sector int, ok bool) {
-	for i := 0; i < len(trackOrder); i++ {
-		track := trackOrder[i]
+	f := d.Format()
+	for i := 0; i < len(f.trackOrder); i++ {
+		track := f.trackOrder[i]
 		if d.TrackInfo(track).Free == 0 {
 			continue
 		}
-		for sector := 0; sector < Geom[track].Sectors; sector++ {
+		for sector := 0; sector < f.Geom[track].Sectors; sector++ {
 			if d.BamRead(track, sector) {
 				return track, sector, true
 			}
@@ -61,14 +47,18 @@ func freeBlockFirst(d Disk) (track int, sector int, ok bool) {
 }
 
 func freeBlockNext(d Disk, track int, sector int) (int, int, bool) {
-	if d.TrackInfo(track).Free == 0 {
+	ti := d.TrackInfo(track)
+	if ti.Free == 0 {
 		return freeBlockFirst(d)
 	}
-	sector = (sector + fileInterleave) % Geom[track].Sectors
-	for {
+	sector = (sector + d.Format().fileInterleave) % ti.Sectors
+	for i := 0; i < ti.Sectors; i++ {
 		if d.BamRead(track, sector) {
 			return track, sector, true
 		}
-		sector = (sector + 1) % Geom[track].Sectors
+		sector = (sector + 1) % ti.Sectors
 	}
+	// The free count for the track doesn't agree with the bitmap. Try
+	// somewhere else instead.
+	return freeBlockFirst(d)
 }
@@ -4,9 +4,16 @@ import (
 	"io"
 )
 
+// Writer stores the contents of a file on the disk. Obtain a Writer with
+// Disk.Create and always call Close when done, otherwise the directory
+// entry is left as an unclosed (splat) file.
 type Writer struct {
-	d Disk
-	e *Editor
+	d      Disk
+	e      *Editor
+	fi     *FileInfo
+	blocks int  // Number of blocks used by the file so far
+	full   bool // True if the current block has no room left
+	closed bool
 }
 
 func newWriter(d Disk, track int, sector int) *Writer {
@@ -19,10 +26,15 @@ func newWriter(d Disk, track int, sector int) *Writer {
 func (w *Writer) seek(track int, sector int) {
 	w.e.Seek(track, sector, 0)
 	w.e.Write(0) // No next track link yet
-	w.e.Write(2) // Two bytes used so far
+	w.e.Write(1) // Index of the last byte used, none so far
+	w.blocks++
+	w.full = false
 }
 
 func (w *Writer) Write(p []byte) (n int, err error) {
+	if w.closed {
+		return 0, ErrClosed
+	}
 	n = 0
 	for _, val := range p {
 		err := w.write(val)
@@ -35,19 +47,14 @@ func (w *Writer) Write(p []byte) (n int, err error) {
 }
 
 func (w *Writer) write(b byte) error {
-	w.e.Poke(int(b))
-
-	// Add to the number of bytes used in this block
-	ptr := w.e.Mark()
-	ptr.Seek(ptr.Track(), ptr.Sector(), 1)
-	ptr.Write(ptr.Peek() + 1)
-
-	if w.e.At() == SectorLen-1 {
+	if w.full {
 		// Find a free block
 		newT, newS, ok := freeBlockNext(w.d, w.e.Track(), w.e.Sector())
 		if !ok {
 			return ErrDiskFull
 		}
+		w.d.BamWrite(newT, newS, false)
+
 		// Add link to next block
 		w.e.Seek(w.e.Track(), w.e.Sector(), 0)
 		w.e.Write(newT)
@@ -55,23 +62,56 @@ func (w *Writer) write(b byte) error {
 
 		// Goto next block and write EOF marker
 		w.seek(newT, newS)
+	}
+
+	w.e.Poke(int(b))
+
+	// Update the index of the last byte used in this block
+	ptr := w.e.Mark()
+	ptr.Seek(ptr.Track(), ptr.Sector(), 1)
+	ptr.Write(ptr.Peek() + 1)
+
+	if w.e.At() == SectorLen-1 {
+		w.full = true
 	} else {
 		w.e.Move(1)
 	}
+	return nil
+}
 
+// Close finishes the file by recording the number of blocks used in
+// the directory entry and marking the file as properly closed.
+func (w *Writer) Close() error {
+	if w.closed {
+		return ErrClosed
+	}
+	w.closed = true
+	if w.fi == nil {
+		return nil
+	}
+	w.fi.Size = w.blocks
+	w.fi.Splat = false
+	writeFileInfo(w.d, w.fi)
 	return nil
 }
 
+// Reader reads the contents of a file by following the chain of blocks
+// on the disk. Obtain a Reader with Disk.Open. ErrBadLink is returned
+// if a link refers to a block that is not on the disk and a Problem is
+// returned if the chain loops or is cross-linked.
 type Reader struct {
 	d          Disk
 	e          *Editor
 	nextTrack  int
 	nextSector int
 	len        int
+	eof        bool
+	err        error       // Set when the next block cannot be read
+	chain      *chainCheck // Blocks already read
 }
 
 func newReader(d Disk, track int, sector int) *Reader {
-	r := &Reader{d: d}
+	r := &Reader{d: d, chain: newChainCheck(d)}
 	r.e = d.Editor()
 	r.seek(track, sector)
 	return r
@@ -89,25 +129,40 @@ func (r *Reader) Read(p []byte) (n int, err error) {
 }
 
 func (r *Reader) seek(track int, sector int) {
+	if err := r.chain.visit(track, sector); err != nil {
+		r.err = err
+		return
+	}
+	if err := r.d.readCheck(track, sector); err != nil {
+		r.err = err
+		return
+	}
 	r.e.Seek(track, sector, 0)
 	r.nextTrack = r.e.Read()
 	if r.nextTrack == 0 {
+		// Index of the last byte used in the final block
 		r.len = r.e.Read()
+		if r.len < 2 {
+			r.eof = true
+		}
 	} else {
 		r.nextSector = r.e.Read()
 	}
 }
 
 func (r *Reader) read() (byte, error) {
-	if r.nextTrack == 0 && r.e.At() == r.len {
-		return 0, io.EOF
+	if r.err != nil {
+		return 0, r.err
 	}
-	if r.e.At() == SectorLen {
-		r.seek(r.nextTrack, r.nextSector)
+	if r.eof {
+		return 0, io.EOF
 	}
+	last := r.nextTrack == 0 && r.e.At() >= r.len
 	val := byte(r.e.Read())
-	// If we moved off the sector, seek to the next block
-	if r.e.At() == 0 {
+	if last {
+		r.eof = true
+	} else if r.e.At() == 0 {
+		// Moved off the sector, seek to the next block
 		r.seek(r.nextTrack, r.nextSector)
 	}
 	return val, nil
@@ -1,7 +1,11 @@
 package d71
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"testing"
 )
 
@@ -35,7 +39,7 @@ func TestReader(t *testing.T) {
 	e := d.Editor()
 	e.Seek(17, 0, 0)
 	e.Write(0)
-	e.Write(4)
+	e.Write(3)
 	e.Write(0xab)
 	e.Write(0xcd)
 
@@ -64,7 +68,7 @@ func TestReaderNextBlock(t *testing.T) {
 	e.Write(8)
 	e.Seek(17, 8, 0)
 	e.Write(0)
-	e.Write(4)
+	e.Write(3)
 	e.Write(0xab)
 	e.Write(0xcd)
 
@@ -91,3 +95,183 @@ func TestReaderNextBlock(t *testing.T) {
 		t.Fatalf("wanted 0xcd ; got %x", buf[1])
 	}
 }
+
+func TestWriterLastByte(t *testing.T) {
+	d := NewDisk("", "")
+	w := newWriter(d, 17, 0)
+	w.Write([]byte{0xaa, 0xbb, 0xcc})
+	want := 4
+	got := int(d[Offset(17, 0, 1)])
+	if want != got {
+		t.Fatalf("wanted %v ; got %v", want, got)
+	}
+}
+
+func TestWriterFullBlock(t *testing.T) {
+	d := NewDisk("", "")
+	w := newWriter(d, 17, 0)
+	w.Write(make([]byte, 254))
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := 0xff
+	got := int(d[Offset(17, 0, 1)])
+	if want != got {
+		t.Fatalf("wanted %v ; got %v", want, got)
+	}
+	if w.blocks != 1 {
+		t.Fatalf("wanted 1 block ; got %v", w.blocks)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	d := NewDisk("", "")
+	w, err := d.Create("HELLO", Prg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	fi, ok := d.Find("HELLO")
+	if !ok {
+		t.Fatalf("file not found")
+	}
+	if fi.Type != Prg {
+		t.Errorf("wanted %v ; got %v", Prg, fi.Type)
+	}
+	if fi.Splat {
+		t.Errorf("wanted file to be closed")
+	}
+	if fi.Size != 4 {
+		t.Errorf("wanted size 4 ; got %v", fi.Size)
+	}
+	if free := d.Info().Free; free != 1328-4 {
+		t.Errorf("wanted free %v ; got %v", 1328-4, free)
+	}
+
+	r, err := d.Open("HELLO")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(data, got) {
+		t.Errorf("data mismatch: wanted %v bytes ; got %v", len(data), len(got))
+	}
+}
+
+func TestCreateNotClosed(t *testing.T) {
+	d := NewDisk("", "")
+	w, _ := d.Create("SPLAT", Seq)
+	w.Write([]byte{1, 2, 3})
+	fi, _ := d.Find("SPLAT")
+	if !fi.Splat {
+		t.Errorf("wanted splat file")
+	}
+}
+
+func TestCreateExists(t *testing.T) {
+	d := NewDisk("", "")
+	w, _ := d.Create("HELLO", Prg)
+	w.Close()
+	_, err := d.Create("HELLO", Seq)
+	if err != ErrFileExists {
+		t.Fatalf("wanted %v ; got %v", ErrFileExists, err)
+	}
+}
+
+func TestCreateNewDirSector(t *testing.T) {
+	d := NewDisk("", "")
+	for i := 0; i < 9; i++ {
+		w, err := d.Create(fmt.Sprintf("FILE %v", i+1), Prg)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		w.Close()
+	}
+	list := d.List()
+	if len(list) != 9 {
+		t.Fatalf("wanted 9 files ; got %v", len(list))
+	}
+	want := "FILE 9"
+	got := list[8].Name
+	if want != got {
+		t.Errorf("wanted %v ; got %v", want, got)
+	}
+	if d.BamRead(DirTrack, 1+dirInterleave) {
+		t.Errorf("wanted new directory sector to be allocated")
+	}
+}
+
+func TestCreateEmpty(t *testing.T) {
+	d := NewDisk("", "")
+	w, _ := d.Create("EMPTY", Seq)
+	w.Close()
+	r, _ := d.Open("EMPTY")
+	got, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("wanted empty file ; got %v bytes", len(got))
+	}
+}
+
+func TestOpenNotFound(t *testing.T) {
+	d := NewDisk("", "")
+	_, err := d.Open("NOPE")
+	if err != ErrNotFound {
+		t.Fatalf("wanted %v ; got %v", ErrNotFound, err)
+	}
+}
+
+func TestReaderBadLink(t *testing.T) {
+	links := []Pos{{Track: 99, Sector: 0}, {Track: 1, Sector: 25}}
+	for _, link := range links {
+		d := NewDisk("", "")
+		writeFile(t, d, "FILE", make([]byte, 1000))
+		fi, _ := d.Find("FILE")
+		e := d.Editor()
+		e.Seek(fi.First.Track, fi.First.Sector, 0)
+		e.Write(link.Track)
+		e.Write(link.Sector)
+
+		r, err := d.Open("FILE")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		_, err = ioutil.ReadAll(r)
+		var perr *DOSError
+		if !errors.As(err, &perr) || perr.Track != link.Track || perr.Sector != link.Sector {
+			t.Errorf("%v: wanted position error ; got %v", link, err)
+		}
+		if !errors.Is(err, ErrBadLink) {
+			t.Errorf("%v: wanted %v ; got %v", link, ErrBadLink, err)
+		}
+	}
+}
+
+func TestReaderBadFirstBlock(t *testing.T) {
+	d := NewDisk("", "")
+	writeFile(t, d, "FILE", []byte{1})
+	fi, _ := d.Find("FILE")
+	fi.First = Pos{Track: 0, Sector: 5}
+	writeFileInfo(d, fi)
+
+	r, err := d.Open("FILE")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := ioutil.ReadAll(r); !errors.Is(err, ErrBadLink) {
+		t.Errorf("wanted %v ; got %v", ErrBadLink, err)
+	}
+}
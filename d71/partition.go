@@ -0,0 +1,190 @@
+package d71
+
+import "sync"
+
+// Number of bytes in a 1581 track
+const trackLen1581 = 40 * SectorLen
+
+var (
+	subdirFormats   = make(map[[2]int]*Format)
+	subdirFormatsMu sync.Mutex
+)
+
+// A 1581 subdirectory is a partition that starts at the beginning of a
+// track, covers whole tracks, is at least three tracks long, and does
+// not include the directory track. The first track holds the header,
+// BAM and directory in the same layout used on track 40.
+//
+// A subdirectory is accessed as a Disk that shares the bytes of the
+// partition with the parent disk. Its format is identified by its size
+// and the header which links to its own directory.
+func subdirFormatOf(d Disk) *Format {
+	if len(d) == 0 || len(d)%trackLen1581 != 0 {
+		return nil
+	}
+	start := int(d[0])
+	tracks := len(d) / trackLen1581
+	if !subdirValid(start, tracks) {
+		return nil
+	}
+	if int(d[1]) != D81.DirSector || int(d[2]) != D81.DosVersion {
+		return nil
+	}
+	return subdirFormat(start, tracks)
+}
+
+func subdirValid(start int, tracks int) bool {
+	end := start + tracks - 1
+	if tracks < 3 || start < 1 || end > D81.MaxTrack {
+		return false
+	}
+	return end < D81.DirTrack || start > D81.DirTrack
+}
+
+// Returns the format for a subdirectory that starts at the given track
+// and is the given number of tracks long. Tracks outside of the
+// subdirectory have no sectors.
+func subdirFormat(start int, tracks int) *Format {
+	subdirFormatsMu.Lock()
+	defer subdirFormatsMu.Unlock()
+
+	key := [2]int{start, tracks}
+	if f, ok := subdirFormats[key]; ok {
+		return f
+	}
+	end := start + tracks - 1
+	f := *D81
+	f.Name = "1581 subdirectory"
+	f.Geom = make([]TrackInfo, f.MaxTrack+1, f.MaxTrack+1)
+	for track := start; track <= end; track++ {
+		f.Geom[track] = TrackInfo{
+			Sectors: 40,
+			Offset:  (track - start) * trackLen1581,
+		}
+	}
+	f.DirTrack = start
+	f.trackOrder = newTrackOrder(start, start, end)
+	f.Len = f.Blocks() * SectorLen
+	subdirFormats[key] = &f
+	return &f
+}
+
+// Returns the positions of the blocks used by a partition and, if the
+// partition runs into a block that is not on the disk, the problem that
+// was found.
+func partitionBlocks(d Disk, fi *FileInfo) ([]Pos, *Problem) {
+	f := d.Format()
+	blocks := make([]Pos, 0, fi.Size)
+	pos := fi.First
+	for i := 0; i < fi.Size; i++ {
+		if d.checkBlock(pos.Track, pos.Sector) != nil {
+			return blocks, &Problem{Name: fi.Name, Pos: pos, Err: ErrBadLink}
+		}
+		blocks = append(blocks, pos)
+		pos.Sector++
+		if pos.Sector >= f.Geom[pos.Track].Sectors {
+			pos.Sector = 0
+			pos.Track++
+		}
+	}
+	return blocks, nil
+}
+
+// CreatePartition reserves an area of contiguous blocks on a 1581 disk
+// starting at the given position. The area is listed in the directory
+// as a CBM file. Returns ErrNoBlock if any of the blocks are already in
+// use and ErrWriteProtect if the disk is write protected.
+func (d Disk) CreatePartition(name string, start Pos, blocks int) error {
+	if err := d.writeCheck(); err != nil {
+		return err
+	}
+	f := d.Format()
+	if !f.partitions {
+		return ErrFileType
+	}
+	if len(name) > MaxFilenameLen {
+		name = name[:MaxFilenameLen]
+	}
+	if !validName(name) {
+		return ErrSyntax
+	}
+	if _, exists := d.findExact(name); exists {
+		return ErrFileExists
+	}
+	fi := &FileInfo{Type: Cbm, Name: name, First: start, Size: blocks}
+	list, p := partitionBlocks(d, fi)
+	if blocks < 1 || p != nil {
+		return ErrBadLink
+	}
+	for _, pos := range list {
+		if f.reserved(pos.Track, pos.Sector) ||
+			!d.BamRead(pos.Track, pos.Sector) {
+			return ErrNoBlock
+		}
+	}
+	entry, err := createDirEntry(d)
+	if err != nil {
+		return err
+	}
+	fi.pos = entry.pos
+	for _, pos := range list {
+		d.BamWrite(pos.Track, pos.Sector, false)
+	}
+	writeFileInfo(d, fi)
+	return nil
+}
+
+// Returns the bytes of the partition with the given name if it can be
+// used as a subdirectory.
+func (d Disk) subdirBytes(name string) (Disk, int, error) {
+	fi, ok := d.findExact(name)
+	if !ok {
+		return nil, 0, ErrNotFound
+	}
+	if fi.Type != Cbm || fi.First.Sector != 0 || fi.Size%40 != 0 {
+		return nil, 0, ErrNotSubdir
+	}
+	tracks := fi.Size / 40
+	f := d.Format()
+	if !subdirValid(fi.First.Track, tracks) {
+		return nil, 0, ErrNotSubdir
+	}
+	start := f.Offset(fi.First.Track, 0, 0)
+	end := start + fi.Size*SectorLen
+	return d[start:end:end], fi.First.Track, nil
+}
+
+// Subdir returns the 1581 subdirectory with the given name as a Disk.
+// The returned Disk shares its contents with this one. Returns
+// ErrNotSubdir if the partition is not formatted as a subdirectory.
+func (d Disk) Subdir(name string) (Disk, error) {
+	sub, _, err := d.subdirBytes(name)
+	if err != nil {
+		return nil, err
+	}
+	if subdirFormatOf(sub) == nil {
+		return nil, ErrNotSubdir
+	}
+	return sub, nil
+}
+
+// FormatSubdir creates an empty file system in the partition with the
+// given name and returns it as a Disk. The partition must be suitable
+// for use as a subdirectory.
+func (d Disk) FormatSubdir(name string, label string, id string) (Disk, error) {
+	sub, start, err := d.subdirBytes(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(label) > 0xf {
+		label = label[:0xf]
+	}
+	if len(id) > 2 {
+		id = id[:2]
+	}
+	for i := range sub {
+		sub[i] = 0
+	}
+	subdirFormat(start, len(sub)/trackLen1581).format(sub, label, id)
+	return sub, nil
+}
@@ -0,0 +1,204 @@
+package d71
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestBlankD81(t *testing.T) {
+	d := NewDiskFormat(D81, "BLANK", "81")
+	if len(d) != 819200 {
+		t.Fatalf("wanted size 819200 ; got %v", len(d))
+	}
+	tests := []struct {
+		pos  Pos
+		want []int
+	}{
+		{Pos{Track: 40, Sector: 0}, []int{0x28, 0x03, 0x44, 0x00, 0x42}},
+		{Pos{Track: 40, Sector: 0, At: 0x16}, []int{0x38, 0x31, 0xa0, 0x33, 0x44, 0xa0, 0xa0, 0x00}},
+		{Pos{Track: 40, Sector: 1}, []int{0x28, 0x02, 0x44, 0xbb, 0x38, 0x31, 0xc0, 0x00}},
+		{Pos{Track: 40, Sector: 2}, []int{0x00, 0xff, 0x44, 0xbb, 0x38, 0x31, 0xc0, 0x00}},
+		{Pos{Track: 40, Sector: 1, At: 0x10}, []int{0x28, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{Pos{Track: 40, Sector: 1, At: 0x10 + 39*6}, []int{0x24, 0xf0, 0xff, 0xff, 0xff, 0xff}},
+		{Pos{Track: 40, Sector: 2, At: 0x10 + 39*6}, []int{0x28, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{Pos{Track: 40, Sector: 3}, []int{0x00, 0xff}},
+	}
+	e := d.Editor()
+	for _, test := range tests {
+		e.Pos = test.pos
+		for i, want := range test.want {
+			if got := e.Read(); want != got {
+				t.Errorf("%+v byte %v: wanted $%02x ; got $%02x", test.pos, i, want, got)
+			}
+		}
+	}
+	info := d.Info()
+	if info.Free != 3160 {
+		t.Errorf("wanted 3160 free ; got %v", info.Free)
+	}
+	if info.Name != "BLANK" || info.ID != "81" || info.DosType != "3D" {
+		t.Errorf("unexpected info: %+v", info)
+	}
+}
+
+func TestD81File(t *testing.T) {
+	d := NewDiskFormat(D81, "", "")
+	data := make([]byte, 3000)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	writeFile(t, d, "FILE", data)
+	fi, _ := d.Find("FILE")
+	want := Pos{Track: 39, Sector: 0}
+	if fi.First != want {
+		t.Errorf("wanted first block %+v ; got %+v", want, fi.First)
+	}
+	r, _ := d.Open("FILE")
+	got, _ := ioutil.ReadAll(r)
+	if !bytes.Equal(data, got) {
+		t.Errorf("data mismatch")
+	}
+	if r := d.Validate(); r.Changed() || len(r.Problems) > 0 {
+		t.Errorf("wanted valid disk ; got %+v", r)
+	}
+}
+
+func TestD81ManyFiles(t *testing.T) {
+	d := NewDiskFormat(D81, "", "")
+	for i := 0; i < 20; i++ {
+		writeFile(t, d, string(rune('A'+i)), []byte{byte(i)})
+	}
+	if n := len(d.List()); n != 20 {
+		t.Errorf("wanted 20 files ; got %v", n)
+	}
+	if d.BamRead(40, 5) {
+		t.Errorf("wanted third directory sector to be allocated")
+	}
+}
+
+func TestCreatePartition(t *testing.T) {
+	d := NewDiskFormat(D81, "", "")
+	if err := d.CreatePartition("PART", Pos{Track: 41}, 120); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fi, found := d.Find("PART")
+	if !found {
+		t.Fatalf("partition not found")
+	}
+	if fi.Type != Cbm || fi.Size != 120 {
+		t.Errorf("unexpected entry: %+v", fi)
+	}
+	if free := d.Info().Free; free != 3160-120 {
+		t.Errorf("wanted %v free ; got %v", 3160-120, free)
+	}
+	if d.BamRead(43, 39) || !d.BamRead(44, 0) {
+		t.Errorf("wanted only partition blocks allocated")
+	}
+	if r := d.Validate(); r.Changed() || len(r.Problems) > 0 {
+		t.Errorf("wanted valid disk ; got %+v", r)
+	}
+	d.Scratch("PART")
+	if free := d.Info().Free; free != 3160 {
+		t.Errorf("wanted 3160 free ; got %v", free)
+	}
+}
+
+func TestCreatePartitionInUse(t *testing.T) {
+	d := NewDiskFormat(D81, "", "")
+	d.BamWrite(42, 7, false)
+	err := d.CreatePartition("PART", Pos{Track: 41}, 120)
+	if err != ErrNoBlock {
+		t.Errorf("wanted %v ; got %v", ErrNoBlock, err)
+	}
+	err = d.CreatePartition("PART", Pos{Track: 40}, 10)
+	if err != ErrNoBlock {
+		t.Errorf("wanted %v ; got %v", ErrNoBlock, err)
+	}
+}
+
+func TestPartitionOffDisk(t *testing.T) {
+	d := NewDiskFormat(D81, "", "")
+	d.CreatePartition("PART", Pos{Track: 1}, 2)
+	fi, _ := d.Find("PART")
+	fi.First = Pos{Track: 1, Sector: 200}
+	writeFileInfo(d, fi)
+
+	r := d.Validate()
+	if len(r.Problems) != 1 || r.Problems[0].Err != ErrBadLink ||
+		r.Problems[0].Pos != fi.First {
+		t.Errorf("wanted bad link at %v ; got %+v", fi.First, r.Problems)
+	}
+	f := d.Format()
+	bam := f.Offset(f.DirTrack, 1, 0)
+	before := append([]byte{}, d[bam:bam+2*SectorLen]...)
+	if _, err := d.Scratch("PART"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(before, d[bam:bam+2*SectorLen]) {
+		t.Errorf("wanted block availability map to be unchanged")
+	}
+	if err := d.CreatePartition("BAD", Pos{Track: 1, Sector: 40}, 1); err != ErrBadLink {
+		t.Errorf("wanted %v ; got %v", ErrBadLink, err)
+	}
+}
+
+func TestCreatePartitionD71(t *testing.T) {
+	d := NewDisk("", "")
+	err := d.CreatePartition("PART", Pos{Track: 1}, 10)
+	if err != ErrFileType {
+		t.Errorf("wanted %v ; got %v", ErrFileType, err)
+	}
+}
+
+func TestSubdir(t *testing.T) {
+	d := NewDiskFormat(D81, "", "")
+	d.CreatePartition("PART", Pos{Track: 41}, 120)
+	d.CreatePartition("SMALL", Pos{Track: 50, Sector: 5}, 10)
+	if _, err := d.Subdir("PART"); err != ErrNotSubdir {
+		t.Errorf("wanted %v ; got %v", ErrNotSubdir, err)
+	}
+	if _, err := d.FormatSubdir("SMALL", "", ""); err != ErrNotSubdir {
+		t.Errorf("wanted %v ; got %v", ErrNotSubdir, err)
+	}
+
+	sub, err := d.FormatSubdir("PART", "SUB", "SD")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	info := sub.Info()
+	if info.Name != "SUB" || info.Free != 80 {
+		t.Errorf("unexpected info: %+v", info)
+	}
+
+	data := []byte("INSIDE")
+	writeFile(t, sub, "FILE", data)
+	fi, _ := sub.Find("FILE")
+	if fi.First.Track != 42 {
+		t.Errorf("wanted file on track 42 ; got %+v", fi.First)
+	}
+	if _, found := d.Find("FILE"); found {
+		t.Errorf("wanted file to only be in subdirectory")
+	}
+
+	sub, err = d.Subdir("PART")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r, err := sub.Open("FILE")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, _ := ioutil.ReadAll(r)
+	if !bytes.Equal(data, got) {
+		t.Errorf("wanted %q ; got %q", data, got)
+	}
+	e := d.Editor()
+	e.Seek(42, 0, 2)
+	if got := e.ReadString(len(data)); got != string(data) {
+		t.Errorf("wanted data in parent at track 42 ; got %q", got)
+	}
+	if r := sub.Validate(); r.Changed() || len(r.Problems) > 0 {
+		t.Errorf("wanted valid subdirectory ; got %+v", r)
+	}
+}
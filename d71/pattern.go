@@ -0,0 +1,110 @@
+package d71
+
+import (
+	"strings"
+)
+
+// Codes used for each file type in a pattern type filter
+var fileTypeCodes = map[byte]FileType{
+	'D': Del,
+	'S': Seq,
+	'P': Prg,
+	'U': Usr,
+	'R': Rel,
+	'C': Cbm,
+}
+
+// Pattern selects files by name and type in the same way as DOS.
+//
+// A question mark in a name matches any character and an asterisk
+// matches the rest of the name. Anything in the pattern after the
+// asterisk is ignored. A shifted space ends the name in the same way it
+// ends a name in the directory. Otherwise, the pattern must be the same
+// length as the name.
+type Pattern struct {
+	Names []string   // Files that match any of these are selected, or all if empty
+	Types []FileType // Files of any of these types are selected, or all if empty
+}
+
+// ParsePattern reads a pattern written as it would be for DOS. Names are
+// separated by commas and can be followed by an equals sign and the
+// first letter of the file types to select, such as A*,B*=P. The
+// pattern may start with a drive number and colon, such as 0:A*, and
+// the drive number is ignored. A pattern without a name selects all
+// files, which only makes sense when listing files. Returns ErrSyntax if
+// a file type is not known.
+func ParsePattern(s string) (Pattern, error) {
+	var p Pattern
+	if i := strings.IndexByte(s, ':'); i >= 0 && strings.Trim(s[:i], "0123456789") == "" {
+		s = s[i+1:]
+	}
+	if i := strings.LastIndexByte(s, '='); i >= 0 {
+		for _, code := range []byte(s[i+1:]) {
+			t, ok := fileTypeCodes[code]
+			if !ok {
+				return Pattern{}, ErrSyntax
+			}
+			p.Types = append(p.Types, t)
+		}
+		s = s[:i]
+	}
+	if s != "" {
+		p.Names = strings.Split(s, ",")
+	}
+	return p, nil
+}
+
+// Match returns true if the file is selected by the pattern.
+func (p Pattern) Match(fi *FileInfo) bool {
+	return p.MatchName(fi.Name) && p.matchType(fi.Type)
+}
+
+// MatchName returns true if the name matches any of the names in the
+// pattern.
+func (p Pattern) MatchName(name string) bool {
+	if len(p.Names) == 0 {
+		return true
+	}
+	for _, pattern := range p.Names {
+		if matchName(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p Pattern) matchType(t FileType) bool {
+	if len(p.Types) == 0 {
+		return true
+	}
+	for _, want := range p.Types {
+		if want == t {
+			return true
+		}
+	}
+	return false
+}
+
+func matchName(pattern string, name string) bool {
+	if i := strings.IndexByte(pattern, 0xa0); i >= 0 {
+		pattern = pattern[:i]
+	}
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case pattern[i] == '*':
+			return true
+		case i >= len(name):
+			return false
+		case pattern[i] != '?' && pattern[i] != name[i]:
+			return false
+		}
+	}
+	return len(pattern) == len(name)
+}
+
+// Returns true if the name can be used for a new file. Wildcards and
+// the characters that separate the parts of a pattern are not allowed,
+// otherwise the file could not be found again by name.
+func validName(name string) bool {
+	return !strings.ContainsAny(name, "*?,=:")
+}
@@ -0,0 +1,116 @@
+package d71
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatchName(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"FILE", "FILE", true},
+		{"FILE", "FILES", false},
+		{"FILES", "FILE", false},
+		{"F*", "FILE", true},
+		{"F*X", "FILE", true},
+		{"*", "", true},
+		{"F?LE", "FILE", true},
+		{"F?LE", "FLE", false},
+		{"????", "FILE", true},
+		{"FILE\xa0XYZ", "FILE", true},
+		{"", "FILE", false},
+	}
+	for _, test := range tests {
+		if got := matchName(test.pattern, test.name); got != test.want {
+			t.Errorf("%q, %q: wanted %v ; got %v", test.pattern, test.name, test.want, got)
+		}
+	}
+}
+
+func TestParsePattern(t *testing.T) {
+	p, err := ParsePattern("0:A*,B?=PS")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(p.Names, ",") != "A*,B?" {
+		t.Errorf("unexpected names: %v", p.Names)
+	}
+	if len(p.Types) != 2 || p.Types[0] != Prg || p.Types[1] != Seq {
+		t.Errorf("unexpected types: %v", p.Types)
+	}
+	if _, err := ParsePattern("A*=X"); err != ErrSyntax {
+		t.Errorf("wanted %v ; got %v", ErrSyntax, err)
+	}
+}
+
+func TestPatternFiles(t *testing.T) {
+	d := NewDisk("", "")
+	writeFile(t, d, "APPLE", []byte{1})
+	writeFile(t, d, "AXE", []byte{1})
+	writeFile(t, d, "BANANA", []byte{1})
+	fi, _ := d.Find("AXE")
+	fi.Type = Seq
+	writeFileInfo(d, fi)
+
+	if fi, ok := d.Find("A*=S"); !ok || fi.Name != "AXE" {
+		t.Errorf("wanted AXE ; got %v", fi)
+	}
+	if fi, ok := d.Find("?A*"); !ok || fi.Name != "BANANA" {
+		t.Errorf("wanted BANANA ; got %v", fi)
+	}
+	if got := fileNames(d.List("A*=P", "B*")); got != "APPLE,BANANA" {
+		t.Errorf("unexpected list: %v", got)
+	}
+	if got := fileNames(d.List()); got != "APPLE,AXE,BANANA" {
+		t.Errorf("unexpected list: %v", got)
+	}
+	n, err := d.Scratch("0:A*")
+	if err != nil || n != 2 {
+		t.Errorf("wanted 2 scratched ; got %v, %v", n, err)
+	}
+	if got := fileNames(d.List()); got != "BANANA" {
+		t.Errorf("unexpected list: %v", got)
+	}
+	for _, name := range []string{"NEW*", "A,B", "A=B", "0:A"} {
+		if _, err := d.Create(name, Prg); err != ErrSyntax {
+			t.Errorf("%v: wanted %v ; got %v", name, ErrSyntax, err)
+		}
+	}
+	if err := d.Rename("BANANA", "A,B"); err != ErrSyntax {
+		t.Errorf("wanted %v ; got %v", ErrSyntax, err)
+	}
+}
+
+func fileNames(list []*FileInfo) string {
+	var s []string
+	for _, fi := range list {
+		s = append(s, fi.Name)
+	}
+	return strings.Join(s, ",")
+}
+
+func TestPatternNoName(t *testing.T) {
+	d := NewDisk("", "")
+	writeFile(t, d, "APPLE", []byte{1})
+	writeFile(t, d, "BANANA", []byte{1})
+	for _, pattern := range []string{"", "0:", "=P"} {
+		if n, err := d.Scratch(pattern); err != ErrSyntax || n != 0 {
+			t.Errorf("scratch %q: wanted %v ; got %v, %v", pattern, ErrSyntax, n, err)
+		}
+		if fi, ok := d.Find(pattern); ok {
+			t.Errorf("find %q: wanted no match ; got %v", pattern, fi.Name)
+		}
+		if _, err := d.Lookup(pattern); err != ErrSyntax {
+			t.Errorf("lookup %q: wanted %v ; got %v", pattern, ErrSyntax, err)
+		}
+		if _, err := d.Open(pattern); err != ErrSyntax {
+			t.Errorf("open %q: wanted %v ; got %v", pattern, ErrSyntax, err)
+		}
+	}
+	if got := fileNames(d.List()); got != "APPLE,BANANA" {
+		t.Errorf("unexpected list: %v", got)
+	}
+}
@@ -0,0 +1,323 @@
+package d71
+
+const (
+	// MaxRecordLen is the largest record length for a relative file
+	MaxRecordLen = 254
+
+	// Number of data block pointers in a side sector
+	sideSectorBlocks = 120
+
+	// Number of side sectors that can be used by a relative file
+	maxSideSectors = 6
+
+	// Number of data bytes in a block
+	blockDataLen = SectorLen - 2
+)
+
+// RelFile provides record access to a relative file. Records are
+// numbered starting from one as done with the BASIC RECORD command.
+type RelFile struct {
+	d       Disk
+	fi      *FileInfo
+	blocks  []Pos // Data blocks in file order
+	sides   []Pos // Side sectors in file order
+	records int   // Number of records in the file
+	closed  bool
+}
+
+// CreateRel adds a new relative file to the disk with the given record
+// length. The file starts out with as many empty records as fit in
+// the first block. Returns ErrWriteProtect if the disk is write
+// protected.
+func (d Disk) CreateRel(name string, recordLen int) (*RelFile, error) {
+	if err := d.writeCheck(); err != nil {
+		return nil, err
+	}
+	if recordLen < 1 || recordLen > MaxRecordLen {
+		return nil, ErrRecordLen
+	}
+	if len(name) > MaxFilenameLen {
+		name = name[:MaxFilenameLen]
+	}
+	if !validName(name) {
+		return nil, ErrSyntax
+	}
+	if _, exists := d.findExact(name); exists {
+		return nil, ErrFileExists
+	}
+	fi, err := createDirEntry(d)
+	if err != nil {
+		return nil, err
+	}
+	track, sector, ok := freeBlockFirst(d)
+	if !ok {
+		return nil, ErrDiskFull
+	}
+	d.BamWrite(track, sector, false)
+	first := Pos{Track: track, Sector: sector}
+
+	track, sector, ok = freeBlockNext(d, track, sector)
+	if !ok {
+		d.BamWrite(first.Track, first.Sector, true)
+		return nil, ErrDiskFull
+	}
+	d.BamWrite(track, sector, false)
+	side := Pos{Track: track, Sector: sector}
+
+	fi.Type = Rel
+	fi.Name = name
+	fi.First = first
+	fi.SideSector = side
+	fi.RecordLen = recordLen
+
+	f := &RelFile{
+		d:      d,
+		fi:     fi,
+		blocks: []Pos{first},
+		sides:  []Pos{side},
+	}
+	f.fill(0, blockDataLen/recordLen)
+	f.update()
+	return f, nil
+}
+
+// OpenRel returns a RelFile for the relative file with the given name.
+// Returns ErrNotFound if there is no such file, ErrFileType if the
+// file is not a relative file, and ErrBadLink or a Problem if the data
+// or side sector chain is damaged.
+func (d Disk) OpenRel(name string) (*RelFile, error) {
+	fi, ok := d.Find(name)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if fi.Type != Rel {
+		return nil, ErrFileType
+	}
+	f := &RelFile{d: d, fi: fi}
+
+	// Use the data block chain to find the blocks in use and the
+	// number of bytes in the last block to compute the record count.
+	var err error
+	if f.blocks, err = d.Chain(fi.First); err != nil {
+		return nil, err
+	}
+	if f.sides, err = d.Chain(fi.SideSector); err != nil {
+		return nil, err
+	}
+	if len(f.blocks) == 0 || len(f.sides) == 0 {
+		return nil, ErrBadLink
+	}
+	e := d.Editor()
+	end := f.blocks[len(f.blocks)-1]
+	e.Seek(end.Track, end.Sector, 1)
+	last := e.Read()
+	size := (len(f.blocks)-1)*blockDataLen + last - 1
+	f.records = size / fi.RecordLen
+	return f, nil
+}
+
+// Records returns the number of records in the file.
+func (f *RelFile) Records() int {
+	return f.records
+}
+
+// RecordLen returns the length of each record in the file.
+func (f *RelFile) RecordLen() int {
+	return f.fi.RecordLen
+}
+
+// ReadRecord returns the contents of record n. Trailing zero bytes are
+// not included, which is how the drive returns a record. Returns
+// ErrNoRecord if the record is past the end of the file.
+func (f *RelFile) ReadRecord(n int) ([]byte, error) {
+	if f.closed {
+		return nil, ErrClosed
+	}
+	if n < 1 || n > f.records {
+		return nil, ErrNoRecord
+	}
+	data := make([]byte, f.fi.RecordLen)
+	e := f.d.Editor()
+	start := (n - 1) * f.fi.RecordLen
+	for i := range data {
+		f.seek(e, start+i)
+		data[i] = byte(e.Peek())
+	}
+	end := len(data)
+	for end > 1 && data[end-1] == 0 {
+		end--
+	}
+	return data[:end], nil
+}
+
+// WriteRecord replaces the contents of record n. The file is expanded
+// with empty records if n is past the end. Data longer than the record
+// length is truncated and ErrOverflow is returned.
+func (f *RelFile) WriteRecord(n int, data []byte) error {
+	if f.closed {
+		return ErrClosed
+	}
+	if n < 1 {
+		return ErrNoRecord
+	}
+	if n > f.records {
+		if err := f.expand(n); err != nil {
+			return err
+		}
+	}
+	var err error
+	if len(data) > f.fi.RecordLen {
+		data = data[:f.fi.RecordLen]
+		err = ErrOverflow
+	}
+	e := f.d.Editor()
+	start := (n - 1) * f.fi.RecordLen
+	for i := 0; i < f.fi.RecordLen; i++ {
+		f.seek(e, start+i)
+		if i < len(data) {
+			e.Poke(int(data[i]))
+		} else {
+			e.Poke(0)
+		}
+	}
+	return err
+}
+
+// Close marks the file as closed. Changes have already been written to
+// the disk.
+func (f *RelFile) Close() error {
+	if f.closed {
+		return ErrClosed
+	}
+	f.closed = true
+	return nil
+}
+
+// Move the editor to the given byte in the file data
+func (f *RelFile) seek(e *Editor, offset int) {
+	block := f.blocks[offset/blockDataLen]
+	e.Seek(block.Track, block.Sector, 2+offset%blockDataLen)
+}
+
+// Mark records from the given index, inclusive, to the end index,
+// exclusive, as empty. The first byte of an empty record is $ff and
+// the remaining bytes are zero.
+func (f *RelFile) fill(start int, end int) {
+	e := f.d.Editor()
+	for n := start; n < end; n++ {
+		for i := 0; i < f.fi.RecordLen; i++ {
+			f.seek(e, n*f.fi.RecordLen+i)
+			if i == 0 {
+				e.Poke(0xff)
+			} else {
+				e.Poke(0)
+			}
+		}
+	}
+	f.records = end
+}
+
+// Add data blocks until record n is present. Any records that can fit
+// in the last block are also added.
+func (f *RelFile) expand(n int) error {
+	size := n * f.fi.RecordLen
+	need := (size + blockDataLen - 1) / blockDataLen
+	if need > maxSideSectors*sideSectorBlocks {
+		return ErrTooLarge
+	}
+	var err error
+	for len(f.blocks) < need {
+		// A new side sector is needed if the current ones are full
+		needSide := len(f.blocks) == len(f.sides)*sideSectorBlocks
+		last := f.blocks[len(f.blocks)-1]
+		block, ok := f.alloc(last)
+		if !ok {
+			err = ErrDiskFull
+			break
+		}
+		if needSide {
+			side, ok := f.alloc(block)
+			if !ok {
+				f.d.BamWrite(block.Track, block.Sector, true)
+				err = ErrDiskFull
+				break
+			}
+			f.sides = append(f.sides, side)
+		}
+		f.blocks = append(f.blocks, block)
+	}
+	// Keep the records added so far even if the disk filled up
+	f.fill(f.records, len(f.blocks)*blockDataLen/f.fi.RecordLen)
+	f.update()
+	return err
+}
+
+// Allocate the next free block after the given one
+func (f *RelFile) alloc(after Pos) (Pos, bool) {
+	track, sector, ok := freeBlockNext(f.d, after.Track, after.Sector)
+	if !ok {
+		return Pos{}, false
+	}
+	f.d.BamWrite(track, sector, false)
+	return Pos{Track: track, Sector: sector}, true
+}
+
+// Write out the block links, side sectors and directory entry so that
+// they reflect the current state of the file.
+func (f *RelFile) update() {
+	e := f.d.Editor()
+
+	// Data block links, the last block holds the index of the last byte
+	// of the last record.
+	size := f.records * f.fi.RecordLen
+	for i, block := range f.blocks {
+		e.Seek(block.Track, block.Sector, 0)
+		if i < len(f.blocks)-1 {
+			next := f.blocks[i+1]
+			e.Write(next.Track)
+			e.Write(next.Sector)
+		} else {
+			e.Write(0)
+			e.Write(size - i*blockDataLen + 1)
+		}
+	}
+
+	for i, side := range f.sides {
+		e.Seek(side.Track, side.Sector, 0)
+		first := i * sideSectorBlocks
+		end := first + sideSectorBlocks
+		if end > len(f.blocks) {
+			end = len(f.blocks)
+		}
+		if i < len(f.sides)-1 {
+			next := f.sides[i+1]
+			e.Write(next.Track)
+			e.Write(next.Sector)
+		} else {
+			e.Write(0)
+			e.Write(0x10 + (end-first)*2 - 1)
+		}
+		e.Write(i)
+		e.Write(f.fi.RecordLen)
+		for j := 0; j < maxSideSectors; j++ {
+			if j < len(f.sides) {
+				e.Write(f.sides[j].Track)
+				e.Write(f.sides[j].Sector)
+			} else {
+				e.Fill(0, 2)
+			}
+		}
+		for j := first; j < first+sideSectorBlocks; j++ {
+			if j < end {
+				e.Write(f.blocks[j].Track)
+				e.Write(f.blocks[j].Sector)
+			} else {
+				e.Fill(0, 2)
+			}
+		}
+	}
+
+	f.fi.Size = len(f.blocks) + len(f.sides)
+	f.fi.Splat = false
+	writeFileInfo(f.d, f.fi)
+}
@@ -0,0 +1,214 @@
+package d71
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestCreateRel(t *testing.T) {
+	d := NewDisk("", "")
+	f, err := d.CreateRel("DATA", 20)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if f.Records() != 12 {
+		t.Errorf("wanted 12 records ; got %v", f.Records())
+	}
+	fi, _ := d.Find("DATA")
+	if fi.Type != Rel || fi.RecordLen != 20 || fi.Size != 2 || fi.Splat {
+		t.Errorf("unexpected entry: %+v", fi)
+	}
+
+	e := d.Editor()
+	e.Seek(fi.SideSector.Track, fi.SideSector.Sector, 0)
+	want := []int{
+		0x00, 0x11, // No next side sector, last byte used
+		0x00, 20, // Side sector number, record length
+		fi.SideSector.Track, fi.SideSector.Sector,
+	}
+	for i, w := range want {
+		if got := e.Read(); w != got {
+			t.Errorf("side sector byte %v: wanted $%02x ; got $%02x", i, w, got)
+		}
+	}
+	e.Seek(fi.SideSector.Track, fi.SideSector.Sector, 0x10)
+	if e.Read() != fi.First.Track || e.Read() != fi.First.Sector {
+		t.Errorf("wanted pointer to first data block")
+	}
+
+	e.Seek(fi.First.Track, fi.First.Sector, 1)
+	if got := e.Read(); got != 12*20+1 {
+		t.Errorf("wanted last byte %v ; got %v", 12*20+1, got)
+	}
+}
+
+func TestRelEmptyRecord(t *testing.T) {
+	d := NewDisk("", "")
+	f, _ := d.CreateRel("DATA", 20)
+	got, err := f.ReadRecord(3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []byte{0xff}
+	if !bytes.Equal(want, got) {
+		t.Errorf("wanted %v ; got %v", want, got)
+	}
+}
+
+func TestRelWriteRecord(t *testing.T) {
+	d := NewDisk("", "")
+	f, _ := d.CreateRel("DATA", 20)
+	want := []byte("SPANS TWO BLOCKS")
+	if err := f.WriteRecord(12, want); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := f.ReadRecord(12)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(want, got) {
+		t.Errorf("wanted %q ; got %q", want, got)
+	}
+}
+
+func TestRelNoRecord(t *testing.T) {
+	d := NewDisk("", "")
+	f, _ := d.CreateRel("DATA", 20)
+	if _, err := f.ReadRecord(13); err != ErrNoRecord {
+		t.Errorf("wanted %v ; got %v", ErrNoRecord, err)
+	}
+	if _, err := f.ReadRecord(0); err != ErrNoRecord {
+		t.Errorf("wanted %v ; got %v", ErrNoRecord, err)
+	}
+}
+
+func TestRelOverflow(t *testing.T) {
+	d := NewDisk("", "")
+	f, _ := d.CreateRel("DATA", 4)
+	if err := f.WriteRecord(1, []byte("ABCDEF")); err != ErrOverflow {
+		t.Errorf("wanted %v ; got %v", ErrOverflow, err)
+	}
+	got, _ := f.ReadRecord(1)
+	if want := []byte("ABCD"); !bytes.Equal(want, got) {
+		t.Errorf("wanted %q ; got %q", want, got)
+	}
+}
+
+func TestRelExpand(t *testing.T) {
+	d := NewDisk("", "")
+	f, _ := d.CreateRel("DATA", 20)
+	want := []byte("RECORD 100")
+	if err := f.WriteRecord(100, want); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	f.Close()
+
+	f, err := d.OpenRel("DATA")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if f.Records() != 101 {
+		t.Errorf("wanted 101 records ; got %v", f.Records())
+	}
+	got, _ := f.ReadRecord(100)
+	if !bytes.Equal(want, got) {
+		t.Errorf("wanted %q ; got %q", want, got)
+	}
+	got, _ = f.ReadRecord(50)
+	if !bytes.Equal([]byte{0xff}, got) {
+		t.Errorf("wanted empty record ; got %q", got)
+	}
+	fi, _ := d.Find("DATA")
+	if fi.Size != 9 {
+		t.Errorf("wanted size 9 ; got %v", fi.Size)
+	}
+	if free := d.Info().Free; free != 1328-9 {
+		t.Errorf("wanted %v free ; got %v", 1328-9, free)
+	}
+}
+
+func TestRelSecondSideSector(t *testing.T) {
+	d := NewDisk("", "")
+	f, _ := d.CreateRel("DATA", MaxRecordLen)
+	if err := f.WriteRecord(121, []byte("LAST")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(f.sides) != 2 {
+		t.Fatalf("wanted 2 side sectors ; got %v", len(f.sides))
+	}
+	e := d.Editor()
+	e.Seek(f.sides[0].Track, f.sides[0].Sector, 0)
+	if e.Read() != f.sides[1].Track || e.Read() != f.sides[1].Sector {
+		t.Errorf("wanted link to second side sector")
+	}
+	e.Seek(f.sides[1].Track, f.sides[1].Sector, 1)
+	if got := e.Read(); got != 0x11 {
+		t.Errorf("wanted last byte $11 ; got $%02x", got)
+	}
+	if got := e.Read(); got != 1 {
+		t.Errorf("wanted side sector number 1 ; got %v", got)
+	}
+	e.Seek(f.sides[0].Track, f.sides[0].Sector, 6)
+	if e.Read() != f.sides[1].Track || e.Read() != f.sides[1].Sector {
+		t.Errorf("wanted second side sector in group table")
+	}
+
+	fi, _ := d.Find("DATA")
+	if fi.Size != 123 {
+		t.Errorf("wanted size 123 ; got %v", fi.Size)
+	}
+	if r := d.Validate(); r.Changed() || len(r.Problems) > 0 {
+		t.Errorf("wanted valid disk ; got %+v", r)
+	}
+}
+
+func TestRelScratch(t *testing.T) {
+	d := NewDisk("", "")
+	f, _ := d.CreateRel("DATA", 20)
+	f.WriteRecord(100, []byte("X"))
+	d.Scratch("DATA")
+	if free := d.Info().Free; free != 1328 {
+		t.Errorf("wanted 1328 free ; got %v", free)
+	}
+}
+
+func TestOpenRelFileType(t *testing.T) {
+	d := NewDisk("", "")
+	writeFile(t, d, "PROGRAM", []byte{1})
+	if _, err := d.OpenRel("PROGRAM"); err != ErrFileType {
+		t.Errorf("wanted %v ; got %v", ErrFileType, err)
+	}
+}
+
+func TestOpenRelBadLink(t *testing.T) {
+	d := NewDisk("", "")
+	f, err := d.CreateRel("REL", 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := f.WriteRecord(5, []byte("DATA")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	f.Close()
+	fi, _ := d.Find("REL")
+	for _, pos := range []Pos{fi.First, fi.SideSector} {
+		e := d.Editor()
+		e.Seek(pos.Track, pos.Sector, 0)
+		saved := []int{e.Read(), e.Read()}
+		e.Seek(pos.Track, pos.Sector, 0)
+		e.Write(99)
+		e.Write(0)
+
+		_, err := d.OpenRel("REL")
+		if !errors.Is(err, ErrBadLink) {
+			t.Errorf("%v: wanted %v ; got %v", pos, ErrBadLink, err)
+		}
+		e.Seek(pos.Track, pos.Sector, 0)
+		e.Write(saved[0])
+		e.Write(saved[1])
+	}
+	if _, err := d.OpenRel("REL"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
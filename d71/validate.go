@@ -0,0 +1,209 @@
+package d71
+
+import (
+	"fmt"
+)
+
+// Problem describes a damaged block chain found during validation or
+// while following a chain of blocks.
+type Problem struct {
+	Name string // Name of the file, empty for the directory
+	Pos  Pos    // Block where the problem was found
+	Err  error  // ErrBadLink, ErrChainLoop, or ErrCrossLink
+}
+
+// Error returns the problem along with the name of the file and the
+// block where it was found. A Problem can be used as an error and
+// matches its Err when used with errors.Is.
+func (p *Problem) Error() string {
+	msg := fmt.Sprintf("%v: track %v, sector %v", p.Err, p.Pos.Track, p.Pos.Sector)
+	if p.Name != "" {
+		return p.Name + ": " + msg
+	}
+	return msg
+}
+
+func (p *Problem) Unwrap() error {
+	return p.Err
+}
+
+// DOSError returns the error reported by the drive for the problem. DOS
+// has no separate errors for damaged chains and reports each as an
+// illegal track or sector at the block where the problem was found.
+func (p *Problem) DOSError() *DOSError {
+	return newDOSError(66, p.Pos.Track, p.Pos.Sector)
+}
+
+// ValidateReport lists everything that was changed or found by Validate.
+type ValidateReport struct {
+	Scratched []string  // Unclosed files that were removed
+	Problems  []Problem // Block chains that could not be followed
+	Allocated []Pos     // Blocks in use that were marked as free
+	Freed     []Pos     // Blocks marked as in use that are not
+	Recounted []int     // Tracks where the free sector count was wrong
+}
+
+// Changed returns true if the validation modified the disk.
+func (r *ValidateReport) Changed() bool {
+	return len(r.Scratched) > 0 || len(r.Allocated) > 0 ||
+		len(r.Freed) > 0 || len(r.Recounted) > 0
+}
+
+// blockMap tracks which blocks are in use while validating.
+type blockMap [][]bool
+
+func newBlockMap(f *Format) blockMap {
+	m := make(blockMap, f.MaxTrack+1, f.MaxTrack+1)
+	for track := 1; track <= f.MaxTrack; track++ {
+		m[track] = make([]bool, f.Geom[track].Sectors, f.Geom[track].Sectors)
+	}
+	return m
+}
+
+func (m blockMap) valid(track int, sector int) bool {
+	return track >= 1 && track < len(m) && sector >= 0 &&
+		sector < len(m[track])
+}
+
+// Mark all blocks in the chain that starts at the given track and sector
+// as used. Returns the positions of the blocks and, if the chain could
+// not be followed to the end, the problem that was found.
+func (m blockMap) markChain(d Disk, track int, sector int) ([]Pos, *Problem) {
+	e := d.Editor()
+	seen := make(map[Pos]bool)
+	chain := make([]Pos, 0)
+	for track != 0 {
+		pos := Pos{Track: track, Sector: sector}
+		if !m.valid(track, sector) {
+			return chain, &Problem{Pos: pos, Err: ErrBadLink}
+		}
+		if seen[pos] {
+			return chain, &Problem{Pos: pos, Err: ErrChainLoop}
+		}
+		if m[track][sector] {
+			return chain, &Problem{Pos: pos, Err: ErrCrossLink}
+		}
+		seen[pos] = true
+		m[track][sector] = true
+		chain = append(chain, pos)
+		e.Seek(track, sector, 0)
+		track = e.Read()
+		sector = e.Read()
+	}
+	return chain, nil
+}
+
+// Mark all blocks in a partition as used.
+func (m blockMap) markPartition(d Disk, fi *FileInfo) *Problem {
+	blocks, p := partitionBlocks(d, fi)
+	for _, pos := range blocks {
+		if m[pos.Track][pos.Sector] {
+			return &Problem{Name: fi.Name, Pos: pos, Err: ErrCrossLink}
+		}
+		m[pos.Track][pos.Sector] = true
+	}
+	return p
+}
+
+// Validate checks the directory and all files on the disk and rebuilds
+// the block availability map from what is found. This is the equivalent
+// of the DOS validate command. Files that were not properly closed are
+// removed. Damaged block chains are reported but are otherwise left
+// alone; the blocks that could be followed remain allocated.
+func (d Disk) Validate() *ValidateReport {
+	f := d.Format()
+	r := &ValidateReport{}
+	used := newBlockMap(f)
+
+	// The header and BAM sectors
+	for track := 1; track <= f.MaxTrack; track++ {
+		for sector := range used[track] {
+			used[track][sector] = f.reserved(track, sector)
+		}
+	}
+
+	// The directory sectors
+	e := d.Editor()
+	e.Seek(f.DirTrack, 0, 0)
+	dir, p := used.markChain(d, e.Read(), e.Read())
+	if p != nil {
+		r.Problems = append(r.Problems, *p)
+	}
+
+	for _, block := range dir {
+		for entry := 0; entry < 8; entry++ {
+			e.Seek(block.Track, block.Sector, entry*0x20)
+			fi := readFileInfo(e)
+			if fi.Type == Del && fi.Splat {
+				continue
+			}
+			if fi.Splat {
+				fi.Type = Del
+				fi.SaveAt = false
+				writeFileInfo(d, fi)
+				r.Scratched = append(r.Scratched, fi.Name)
+				continue
+			}
+			if fi.Type == Cbm {
+				if p := used.markPartition(d, fi); p != nil {
+					r.Problems = append(r.Problems, *p)
+				}
+				continue
+			}
+			if _, p := used.markChain(d, fi.First.Track, fi.First.Sector); p != nil {
+				p.Name = fi.Name
+				r.Problems = append(r.Problems, *p)
+			}
+			if fi.Type != Rel {
+				continue
+			}
+			if _, p := used.markChain(d, fi.SideSector.Track, fi.SideSector.Sector); p != nil {
+				p.Name = fi.Name
+				r.Problems = append(r.Problems, *p)
+			}
+		}
+	}
+
+	d.rebuildBam(used, r)
+	return r
+}
+
+// Write out a new block availability map, including the free counts for
+// each track, based on the blocks marked as used.
+func (d Disk) rebuildBam(used blockMap, r *ValidateReport) {
+	e := d.Editor()
+	for track := 1; track < len(used); track++ {
+		free := 0
+		for sector, inUse := range used[track] {
+			if d.BamRead(track, sector) == inUse {
+				pos := Pos{Track: track, Sector: sector}
+				if inUse {
+					r.Allocated = append(r.Allocated, pos)
+				} else {
+					r.Freed = append(r.Freed, pos)
+				}
+			}
+			if !inUse {
+				free++
+			}
+		}
+
+		// Clear out the entire bitmap for the track, including any
+		// bits past the last sector, and then set the free sectors.
+		off, _ := bamPos(e, track, 0)
+		e.Move(off).Fill(0, (len(used[track])+7)/8)
+		for sector, inUse := range used[track] {
+			if inUse {
+				continue
+			}
+			off, mask := bamPos(e, track, sector)
+			e.Move(off).Poke(e.Peek() | mask)
+		}
+
+		freeCountPos(e, track)
+		if e.Peek() != free {
+			r.Recounted = append(r.Recounted, track)
+			e.Poke(free)
+		}
+	}
+}
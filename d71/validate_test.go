@@ -0,0 +1,130 @@
+package d71
+
+import "testing"
+
+func TestValidateClean(t *testing.T) {
+	d := NewDisk("", "")
+	writeFile(t, d, "FILE 1", make([]byte, 1000))
+	writeFile(t, d, "FILE 2", make([]byte, 10))
+	r := d.Validate()
+	if r.Changed() {
+		t.Errorf("wanted no changes ; got %+v", r)
+	}
+	if len(r.Problems) != 0 {
+		t.Errorf("wanted no problems ; got %+v", r.Problems)
+	}
+}
+
+func TestValidateBlankDisk(t *testing.T) {
+	d := NewDisk("", "")
+	want := NewDisk("", "")
+	d.Validate()
+	for i := range want {
+		if want[i] != d[i] {
+			t.Fatalf("wanted disk to be unchanged at $%x", i)
+		}
+	}
+}
+
+func TestValidateRebuildBam(t *testing.T) {
+	d := NewDisk("", "")
+	writeFile(t, d, "FILE 1", make([]byte, 10))
+	fi, _ := d.Find("FILE 1")
+	d.BamWrite(fi.First.Track, fi.First.Sector, true)
+	d.BamWrite(40, 3, false)
+	e := d.Editor()
+	freeCountPos(e, 5)
+	e.Poke(3)
+
+	r := d.Validate()
+	if len(r.Allocated) != 1 || r.Allocated[0] != fi.First {
+		t.Errorf("wanted %+v allocated ; got %+v", fi.First, r.Allocated)
+	}
+	want := Pos{Track: 40, Sector: 3}
+	if len(r.Freed) != 1 || r.Freed[0] != want {
+		t.Errorf("wanted %+v freed ; got %+v", want, r.Freed)
+	}
+	if len(r.Recounted) != 3 || r.Recounted[0] != 5 {
+		t.Errorf("wanted track 5 recounted ; got %v", r.Recounted)
+	}
+	if d.BamRead(fi.First.Track, fi.First.Sector) {
+		t.Errorf("wanted file block to be allocated")
+	}
+	if !d.BamRead(40, 3) {
+		t.Errorf("wanted block to be free")
+	}
+	if free := d.TrackInfo(5).Free; free != 21 {
+		t.Errorf("wanted 21 free ; got %v", free)
+	}
+	if free := d.Info().Free; free != 1328-1 {
+		t.Errorf("wanted %v free ; got %v", 1328-1, free)
+	}
+}
+
+func TestValidateSplat(t *testing.T) {
+	d := NewDisk("", "")
+	w, _ := d.Create("SPLAT", Seq)
+	w.Write(make([]byte, 1000))
+
+	r := d.Validate()
+	if len(r.Scratched) != 1 || r.Scratched[0] != "SPLAT" {
+		t.Errorf("wanted SPLAT scratched ; got %v", r.Scratched)
+	}
+	if _, found := d.Find("SPLAT"); found {
+		t.Errorf("wanted splat file removed")
+	}
+	if free := d.Info().Free; free != 1328 {
+		t.Errorf("wanted 1328 free ; got %v", free)
+	}
+}
+
+func TestValidateCrossLink(t *testing.T) {
+	d := NewDisk("", "")
+	writeFile(t, d, "FILE 1", make([]byte, 10))
+	writeFile(t, d, "FILE 2", make([]byte, 10))
+	fi1, _ := d.Find("FILE 1")
+	fi2, _ := d.Find("FILE 2")
+	fi2.First = fi1.First
+	writeFileInfo(d, fi2)
+
+	r := d.Validate()
+	if len(r.Problems) != 1 {
+		t.Fatalf("wanted one problem ; got %+v", r.Problems)
+	}
+	p := r.Problems[0]
+	if p.Name != "FILE 2" || p.Err != ErrCrossLink || p.Pos != fi1.First {
+		t.Errorf("unexpected problem: %+v", p)
+	}
+}
+
+func TestValidateLoop(t *testing.T) {
+	d := NewDisk("", "")
+	writeFile(t, d, "FILE 1", make([]byte, 10))
+	fi, _ := d.Find("FILE 1")
+	e := d.Editor()
+	e.Seek(fi.First.Track, fi.First.Sector, 0)
+	e.Write(fi.First.Track)
+	e.Write(fi.First.Sector)
+
+	r := d.Validate()
+	if len(r.Problems) != 1 || r.Problems[0].Err != ErrChainLoop {
+		t.Fatalf("wanted loop problem ; got %+v", r.Problems)
+	}
+}
+
+func TestValidateBadLink(t *testing.T) {
+	d := NewDisk("", "")
+	writeFile(t, d, "FILE 1", make([]byte, 10))
+	fi, _ := d.Find("FILE 1")
+	e := d.Editor()
+	e.Seek(fi.First.Track, fi.First.Sector, 0)
+	e.Write(99)
+	e.Write(0)
+
+	r := d.Validate()
+	want := Pos{Track: 99}
+	if len(r.Problems) != 1 || r.Problems[0].Err != ErrBadLink ||
+		r.Problems[0].Pos != want {
+		t.Fatalf("wanted bad link problem ; got %+v", r.Problems)
+	}
+}
@@ -0,0 +1,181 @@
+package gcr
+
+import (
+	"github.com/blackchip-org/vt128/d71"
+)
+
+const (
+	syncLen      = 5 // Bytes of $ff written for each sync mark
+	headerGapLen = 9 // Bytes of $55 between the header and data block
+	gapByte      = 0x55
+)
+
+// Number of bytes on a track for each speed zone
+var trackSizes = [4]int{6250, 6666, 7142, 7692}
+
+// Speed zone used for a track with the given number of sectors
+var speedZones = map[int]int{17: 0, 18: 1, 19: 2, 21: 3}
+
+// Error table codes for problems found when decoding
+var errorCodes = map[error]int{
+	ErrDataChecksum:   d71.ErrCodeDataChecksum,
+	ErrDecode:         d71.ErrCodeDecode,
+	ErrHeaderChecksum: d71.ErrCodeHeadChecksum,
+	ErrNoData:         d71.ErrCodeNoData,
+}
+
+// Returns the format of the disk stored in the image
+func (img *Image) format() *d71.Format {
+	if img.DoubleSided {
+		return d71.D71
+	}
+	if t := img.Track(36); t != nil && len(t.Report().Sectors) > 0 {
+		return d71.D64Ext
+	}
+	return d71.D64
+}
+
+// ToDisk decodes each sector in the image and stores them in a disk
+// image. G64 images become 1541 disks and G71 images become 1571 disks.
+// Sectors that could not be read are recorded in the error table of the
+// disk which is only added when needed. Returns ErrNoSectors if nothing
+// could be decoded.
+func (img *Image) ToDisk() (d71.Disk, error) {
+	f := img.format()
+	d := make(d71.Disk, f.Len)
+	codes := make(map[d71.Pos]int)
+	found := false
+
+	// The drive compares the ID in each header with the one found when
+	// the disk was initialized on the directory track.
+	var diskID *[2]byte
+	if t := img.Track(f.DirTrack); t != nil {
+		for _, s := range t.Report().Sectors {
+			if s.Err != ErrHeaderChecksum && s.Track == f.DirTrack {
+				diskID = &s.ID
+				break
+			}
+		}
+	}
+
+	for track := 1; track <= f.MaxTrack; track++ {
+		sectors := f.Geom[track].Sectors
+		seen := make(map[int]bool)
+		t := img.Track(track)
+		if t == nil {
+			for sector := 0; sector < sectors; sector++ {
+				codes[d71.Pos{Track: track, Sector: sector}] = d71.ErrCodeNoSync
+			}
+			continue
+		}
+		r := t.Report()
+		for _, s := range r.Sectors {
+			if s.Track != track || s.Sector >= sectors || seen[s.Sector] {
+				continue
+			}
+			pos := d71.Pos{Track: track, Sector: s.Sector}
+			if s.Data != nil {
+				copy(d[f.Offset(track, s.Sector, 0):], s.Data)
+				found = true
+			}
+			switch {
+			case s.Err != nil:
+				codes[pos] = errorCodes[s.Err]
+			case diskID != nil && s.ID != *diskID:
+				codes[pos] = d71.ErrCodeIDMismatch
+			default:
+				seen[s.Sector] = true
+				delete(codes, pos)
+				continue
+			}
+			// Keep looking in case a good copy is found later
+		}
+		for sector := 0; sector < sectors; sector++ {
+			pos := d71.Pos{Track: track, Sector: sector}
+			if _, bad := codes[pos]; !bad && !seen[sector] {
+				if r.Syncs == 0 {
+					codes[pos] = d71.ErrCodeNoSync
+				} else {
+					codes[pos] = d71.ErrCodeNoHeader
+				}
+			}
+		}
+	}
+	if !found {
+		return nil, ErrNoSectors
+	}
+	if len(codes) > 0 {
+		d = d.WithErrorTable()
+		for pos, code := range codes {
+			if err := d.SetBlockError(pos.Track, pos.Sector, code); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return d, nil
+}
+
+// FromDisk encodes the contents of a 1541 or 1571 disk image as GCR.
+// Blocks flagged with a header or data block checksum error in the
+// error table of the disk are written with a bad checksum. Returns
+// ErrUnsupported for other disk formats.
+func FromDisk(d d71.Disk) (*Image, error) {
+	f := d.Format()
+	if f != d71.D64 && f != d71.D64Ext && f != d71.D71 {
+		return nil, ErrUnsupported
+	}
+	img := New(f.DoubleSided)
+	id := []byte(d.Info().ID + "  ")[:2]
+	for track := 1; track <= f.MaxTrack; track++ {
+		sectors := f.Geom[track].Sectors
+		speed := speedZones[sectors]
+		err := img.SetTrack(&Track{
+			Track: track,
+			Speed: speed,
+			Data:  encodeTrack(d, f, track, id, trackSizes[speed]),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return img, nil
+}
+
+func encodeTrack(d d71.Disk, f *d71.Format, track int, id []byte, size int) []byte {
+	sectors := f.Geom[track].Sectors
+	sectorLen := syncLen + headerLen*10/8 + headerGapLen + syncLen + blockLen*10/8
+	gap := (size - sectors*sectorLen) / sectors
+
+	out := make([]byte, 0, size)
+	fill := func(b byte, n int) {
+		for i := 0; i < n; i++ {
+			out = append(out, b)
+		}
+	}
+	for sector := 0; sector < sectors; sector++ {
+		code := d.BlockError(track, sector)
+
+		h := []byte{headerID, 0, byte(sector), byte(track), id[1], id[0], 0x0f, 0x0f}
+		h[1] = checksum(h[2:6])
+		if code == d71.ErrCodeHeadChecksum {
+			h[1] ^= 0xff
+		}
+		fill(0xff, syncLen)
+		out = append(out, Encode(h)...)
+		fill(gapByte, headerGapLen)
+
+		offset := f.Offset(track, sector, 0)
+		block := make([]byte, blockLen)
+		block[0] = dataID
+		copy(block[1:257], d[offset:offset+d71.SectorLen])
+		block[257] = checksum(block[1:257])
+		if code == d71.ErrCodeDataChecksum {
+			block[257] ^= 0xff
+		}
+		fill(0xff, syncLen)
+		out = append(out, Encode(block)...)
+		fill(gapByte, gap)
+	}
+	fill(gapByte, size-len(out))
+	return out
+}
@@ -0,0 +1,152 @@
+package gcr
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/blackchip-org/vt128/d71"
+)
+
+func newTestDisk(t *testing.T, f *d71.Format) d71.Disk {
+	d := d71.NewDiskFormat(f, "GCR TEST", "AB")
+	w, err := d.Create("FILE", d71.Prg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	w.Write(data)
+	w.Close()
+	return d
+}
+
+// Rotate the bits in a track so that sectors are no longer byte aligned
+func rotate(data []byte, bits int) []byte {
+	b := bitstream{data: data, n: len(data) * 8}
+	out := make([]byte, len(data))
+	for i := 0; i < b.n; i++ {
+		out[i/8] |= byte(b.bit(i+bits) << uint(7-i%8))
+	}
+	return out
+}
+
+func TestRoundTripD71(t *testing.T) {
+	d := newTestDisk(t, d71.D71)
+	img, err := FromDisk(d)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	img, err = Parse(img.Bytes())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !img.DoubleSided {
+		t.Fatalf("wanted double sided")
+	}
+	d2, err := img.ToDisk()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d2.HasErrorTable() {
+		t.Errorf("wanted no error table")
+	}
+	if !bytes.Equal(d, d2) {
+		t.Fatalf("disks are not the same")
+	}
+}
+
+func TestRoundTripD64(t *testing.T) {
+	d := newTestDisk(t, d71.D64)
+	img, _ := FromDisk(d)
+	if img.DoubleSided || len(img.Tracks) != 84 {
+		t.Fatalf("wanted single sided image")
+	}
+	d2, err := img.ToDisk()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d2.Format() != d71.D64 {
+		t.Fatalf("wanted D64 format ; got %v", d2.Format().Name)
+	}
+	if !bytes.Equal(d, d2) {
+		t.Fatalf("disks are not the same")
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+	d := d71.NewDiskFormat(d71.D81, "", "")
+	if _, err := FromDisk(d); err != ErrUnsupported {
+		t.Fatalf("wanted unsupported error ; got %v", err)
+	}
+}
+
+func TestTrackReport(t *testing.T) {
+	img, _ := FromDisk(d71.NewDisk("", "AB"))
+	tr := img.Track(18)
+	tr.Data = rotate(tr.Data, 1234)
+	r := tr.Report()
+	if r.Speed != 2 {
+		t.Errorf("wanted speed 2 ; got %v", r.Speed)
+	}
+	if r.Len != 7142 {
+		t.Errorf("wanted length 7142 ; got %v", r.Len)
+	}
+	if r.Syncs != 38 {
+		t.Errorf("wanted 38 syncs ; got %v", r.Syncs)
+	}
+	if len(r.Sectors) != 19 {
+		t.Fatalf("wanted 19 sectors ; got %v", len(r.Sectors))
+	}
+	for _, s := range r.Sectors {
+		if s.Err != nil {
+			t.Errorf("sector %v: unexpected error: %v", s.Sector, s.Err)
+		}
+		if s.Track != 18 || s.ID != [2]byte{'A', 'B'} {
+			t.Errorf("unexpected header: %+v", s)
+		}
+	}
+}
+
+func TestChecksumErrors(t *testing.T) {
+	d := newTestDisk(t, d71.D71).WithErrorTable()
+	d.SetBlockError(17, 0, d71.ErrCodeDataChecksum)
+	d.SetBlockError(17, 1, d71.ErrCodeHeadChecksum)
+	img, _ := FromDisk(d)
+
+	d2, err := img.ToDisk()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !d2.HasErrorTable() {
+		t.Fatalf("wanted error table")
+	}
+	if code := d2.BlockError(17, 0); code != d71.ErrCodeDataChecksum {
+		t.Errorf("wanted data checksum error ; got %v", code)
+	}
+	if code := d2.BlockError(17, 1); code != d71.ErrCodeHeadChecksum {
+		t.Errorf("wanted header checksum error ; got %v", code)
+	}
+	if code := d2.BlockError(17, 2); code != d71.ErrCodeOK {
+		t.Errorf("wanted no error ; got %v", code)
+	}
+}
+
+func TestMissingTrack(t *testing.T) {
+	img, _ := FromDisk(d71.NewDisk("", ""))
+	img.Tracks[img.index(1)] = nil
+	d, err := img.ToDisk()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if code := d.BlockError(1, 0); code != d71.ErrCodeNoSync {
+		t.Errorf("wanted no sync error ; got %v", code)
+	}
+}
+
+func TestNoSectors(t *testing.T) {
+	if _, err := New(false).ToDisk(); err != ErrNoSectors {
+		t.Fatalf("wanted no sectors error ; got %v", err)
+	}
+}
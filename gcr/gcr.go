@@ -0,0 +1,138 @@
+// Package gcr reads and writes disk images that store the group coded
+// recording (GCR) bit stream found on each track of a 1541 or 1571 disk.
+package gcr
+
+import "fmt"
+
+var (
+	ErrDataChecksum   = fmt.Errorf("checksum error in data block")
+	ErrDecode         = fmt.Errorf("byte decoding error")
+	ErrFormat         = fmt.Errorf("not a G64 or G71 image")
+	ErrHeaderChecksum = fmt.Errorf("checksum error in header block")
+	ErrNoData         = fmt.Errorf("data block not present")
+	ErrNoSectors      = fmt.Errorf("no sectors found")
+	ErrTrack          = fmt.Errorf("track not in image")
+	ErrUnsupported    = fmt.Errorf("disk format cannot be stored as GCR")
+)
+
+const (
+	headerID = 0x08 // First byte of a sector header
+	dataID   = 0x07 // First byte of a data block
+
+	headerLen = 8   // Decoded length of a sector header
+	blockLen  = 260 // Decoded length of a data block
+
+	// Minimum number of one bits in a row that are seen as a sync mark
+	minSyncBits = 10
+)
+
+// Each nybble is stored as five bits so that there are never more than
+// two zero bits in a row.
+var encodeTable = [16]byte{
+	0x0a, 0x0b, 0x12, 0x13, 0x0e, 0x0f, 0x16, 0x17,
+	0x09, 0x19, 0x1a, 0x1b, 0x0d, 0x1d, 0x1e, 0x15,
+}
+
+var decodeTable [32]int
+
+func init() {
+	for i := range decodeTable {
+		decodeTable[i] = -1
+	}
+	for nybble, code := range encodeTable {
+		decodeTable[code] = nybble
+	}
+}
+
+// Encode converts bytes into GCR. Every four bytes are stored as five
+// bytes. If the length of data is not a multiple of four, the last byte
+// is padded with zero bits.
+func Encode(data []byte) []byte {
+	out := make([]byte, 0, (len(data)*10+7)/8)
+	acc := uint32(0)
+	n := 0
+	for _, b := range data {
+		acc = acc<<10 | uint32(encodeTable[b>>4])<<5 | uint32(encodeTable[b&0xf])
+		n += 10
+		for n >= 8 {
+			n -= 8
+			out = append(out, byte(acc>>uint(n)))
+		}
+	}
+	if n > 0 {
+		out = append(out, byte(acc<<uint(8-n)))
+	}
+	return out
+}
+
+// Decode converts GCR back into bytes. Every five bytes are decoded
+// into four. Returns ErrDecode if an invalid code is found.
+func Decode(data []byte) ([]byte, error) {
+	b := bitstream{data: data, n: len(data) * 8}
+	return b.decode(0, len(data)*8/10)
+}
+
+// A track as a stream of bits that wraps around at the end.
+type bitstream struct {
+	data []byte
+	n    int // Number of bits
+}
+
+func (b bitstream) bit(i int) int {
+	i %= b.n
+	return int(b.data[i/8]>>uint(7-i%8)) & 1
+}
+
+// Decode n bytes starting at bit position pos
+func (b bitstream) decode(pos int, n int) ([]byte, error) {
+	out := make([]byte, n)
+	for i := range out {
+		hi := decodeTable[b.code(pos)]
+		lo := decodeTable[b.code(pos+5)]
+		if hi < 0 || lo < 0 {
+			return out[:i], ErrDecode
+		}
+		out[i] = byte(hi<<4 | lo)
+		pos += 10
+	}
+	return out, nil
+}
+
+// Five bits starting at pos
+func (b bitstream) code(pos int) int {
+	v := 0
+	for i := 0; i < 5; i++ {
+		v = v<<1 | b.bit(pos+i)
+	}
+	return v
+}
+
+// Returns the bit positions found just after each sync mark. The track
+// wraps around so a sync mark can start at the end of the data and
+// finish at the beginning.
+func (b bitstream) syncs() []int {
+	var marks []int
+	ones := 0
+	// Go around twice so that the run of ones before the first zero bit
+	// is counted correctly. Only marks that end on the second time
+	// around are kept.
+	for i := 0; i < b.n*2; i++ {
+		if b.bit(i) == 1 {
+			ones++
+			continue
+		}
+		if ones >= minSyncBits && i >= b.n {
+			marks = append(marks, i-b.n)
+		}
+		ones = 0
+	}
+	return marks
+}
+
+func checksum(data []byte) byte {
+	sum := byte(0)
+	for _, v := range data {
+		sum ^= v
+	}
+	return sum
+}
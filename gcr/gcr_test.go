@@ -0,0 +1,49 @@
+package gcr
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	// Header ID byte followed by three zeros
+	got := Encode([]byte{0x08, 0x00, 0x00, 0x00})
+	want := []byte{0x52, 0x54, 0xa5, 0x29, 0x4a}
+	if !bytes.Equal(want, got) {
+		t.Fatalf("wanted %x ; got %x", want, got)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	data := []byte{0x07, 0x12, 0xab, 0xff, 0x00, 0x5a, 0xc3, 0x3c}
+	got, err := Decode(Encode(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(data, got) {
+		t.Fatalf("wanted %x ; got %x", data, got)
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	_, err := Decode([]byte{0x00, 0x00, 0x00, 0x00, 0x00})
+	if err != ErrDecode {
+		t.Fatalf("wanted decode error ; got %v", err)
+	}
+}
+
+func TestSyncs(t *testing.T) {
+	b := bitstream{data: []byte{0x55, 0xff, 0xf5, 0x55}, n: 32}
+	got := b.syncs()
+	if len(got) != 1 || got[0] != 20 {
+		t.Fatalf("wanted sync at 20 ; got %v", got)
+	}
+}
+
+func TestSyncsWrap(t *testing.T) {
+	b := bitstream{data: []byte{0xf5, 0x55, 0x55, 0xff}, n: 32}
+	got := b.syncs()
+	if len(got) != 1 || got[0] != 4 {
+		t.Fatalf("wanted sync at 4 ; got %v", got)
+	}
+}
@@ -0,0 +1,211 @@
+package gcr
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+)
+
+const (
+	// Sig1541 is the signature found at the start of a G64 image
+	Sig1541 = "GCR-1541"
+
+	// Sig1571 is the signature found at the start of a G71 image
+	Sig1571 = "GCR-1571"
+
+	// DefaultMaxTrackLen is the space reserved for each track in images
+	// created by this package
+	DefaultMaxTrackLen = 7928
+
+	// VariableSpeed is used for a track that has a speed zone for each
+	// group of four bytes instead of a single speed for the whole track
+	VariableSpeed = -1
+
+	// Number of half tracks stored for each side of the disk
+	halfTracks = 84
+
+	// Number of bytes before the track offset table
+	headerLen64 = 12
+)
+
+// Image is a G64 or G71 disk image. Tracks are stored as found in the
+// image which includes entries for each half track.
+type Image struct {
+	DoubleSided bool     // Set for G71 images
+	MaxTrackLen int      // Space reserved for each track
+	Tracks      []*Track // One for each half track, nil if not present
+}
+
+// Track is the GCR data found on a track, or half track, of the disk.
+type Track struct {
+	Track      int    // Track number
+	Half       bool   // Set if this is the half track after Track
+	Speed      int    // Speed zone from 0 to 3 or VariableSpeed
+	SpeedZones []byte // Speed zone bytes used with VariableSpeed
+	Data       []byte
+}
+
+// New returns an image without any tracks.
+func New(doubleSided bool) *Image {
+	n := halfTracks
+	if doubleSided {
+		n *= 2
+	}
+	return &Image{
+		DoubleSided: doubleSided,
+		MaxTrackLen: DefaultMaxTrackLen,
+		Tracks:      make([]*Track, n),
+	}
+}
+
+// Returns the track number for an entry in the track table
+func trackOf(i int) (track int, half bool) {
+	side := i / halfTracks
+	i %= halfTracks
+	return i/2 + 1 + side*35, i%2 == 1
+}
+
+// Returns the entry in the track table for a track number. On a double
+// sided image, tracks 36 and up are found on the second side.
+func (img *Image) index(track int) int {
+	if img.DoubleSided && track >= 36 {
+		return halfTracks + (track-36)*2
+	}
+	return (track - 1) * 2
+}
+
+// Track returns the data for the given whole track or nil if the track
+// is not in the image.
+func (img *Image) Track(track int) *Track {
+	i := img.index(track)
+	if track < 1 || i >= len(img.Tracks) {
+		return nil
+	}
+	return img.Tracks[i]
+}
+
+// SetTrack stores the data for a whole track, or the half track after
+// it. Returns ErrTrack if there is no entry for the track in the image.
+func (img *Image) SetTrack(t *Track) error {
+	i := img.index(t.Track)
+	if t.Half {
+		i++
+	}
+	if t.Track < 1 || i >= len(img.Tracks) {
+		return ErrTrack
+	}
+	img.Tracks[i] = t
+	return nil
+}
+
+// Parse reads an image from the contents of a G64 or G71 file.
+func Parse(data []byte) (*Image, error) {
+	if len(data) < headerLen64 {
+		return nil, ErrFormat
+	}
+	img := &Image{}
+	switch string(data[:8]) {
+	case Sig1541:
+	case Sig1571:
+		img.DoubleSided = true
+	default:
+		return nil, ErrFormat
+	}
+	n := int(data[9])
+	img.MaxTrackLen = int(binary.LittleEndian.Uint16(data[10:]))
+	img.Tracks = make([]*Track, n)
+	speeds := headerLen64 + n*4
+	if len(data) < speeds+n*4 {
+		return nil, ErrFormat
+	}
+	zoneLen := (img.MaxTrackLen + 3) / 4
+
+	for i := 0; i < n; i++ {
+		offset := int(binary.LittleEndian.Uint32(data[headerLen64+i*4:]))
+		speed := int(binary.LittleEndian.Uint32(data[speeds+i*4:]))
+		if offset == 0 {
+			continue
+		}
+		if offset+2 > len(data) {
+			return nil, ErrFormat
+		}
+		size := int(binary.LittleEndian.Uint16(data[offset:]))
+		if offset+2+size > len(data) {
+			return nil, ErrFormat
+		}
+		t := &Track{Speed: speed}
+		t.Track, t.Half = trackOf(i)
+		t.Data = append([]byte{}, data[offset+2:offset+2+size]...)
+		if speed > 3 {
+			if speed+zoneLen > len(data) {
+				return nil, ErrFormat
+			}
+			t.Speed = VariableSpeed
+			t.SpeedZones = append([]byte{}, data[speed:speed+zoneLen]...)
+		}
+		img.Tracks[i] = t
+	}
+	return img, nil
+}
+
+// Bytes returns the contents of the image as stored in a G64 or G71
+// file. If a track is longer than MaxTrackLen, the length of the longest
+// track is used instead so that no track is cut short.
+func (img *Image) Bytes() []byte {
+	n := len(img.Tracks)
+	maxLen := img.MaxTrackLen
+	for _, t := range img.Tracks {
+		if t != nil && len(t.Data) > maxLen {
+			maxLen = len(t.Data)
+		}
+	}
+	zoneLen := (maxLen + 3) / 4
+	speeds := headerLen64 + n*4
+	out := make([]byte, speeds+n*4)
+
+	sig := Sig1541
+	if img.DoubleSided {
+		sig = Sig1571
+	}
+	copy(out, sig)
+	out[9] = byte(n)
+	binary.LittleEndian.PutUint16(out[10:], uint16(maxLen))
+
+	for i, t := range img.Tracks {
+		if t == nil {
+			continue
+		}
+		binary.LittleEndian.PutUint32(out[headerLen64+i*4:], uint32(len(out)))
+		block := make([]byte, 2+maxLen)
+		binary.LittleEndian.PutUint16(block, uint16(len(t.Data)))
+		copy(block[2:], t.Data)
+		out = append(out, block...)
+	}
+	for i, t := range img.Tracks {
+		if t == nil {
+			continue
+		}
+		if t.Speed != VariableSpeed {
+			binary.LittleEndian.PutUint32(out[speeds+i*4:], uint32(t.Speed))
+			continue
+		}
+		binary.LittleEndian.PutUint32(out[speeds+i*4:], uint32(len(out)))
+		zones := make([]byte, zoneLen)
+		copy(zones, t.SpeedZones)
+		out = append(out, zones...)
+	}
+	return out
+}
+
+// Import loads a G64 or G71 image from a file.
+func Import(filename string) (*Image, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Export saves the image to a file.
+func (img *Image) Export(filename string) error {
+	return ioutil.WriteFile(filename, img.Bytes(), 0644)
+}
@@ -0,0 +1,100 @@
+package gcr
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseHeader(t *testing.T) {
+	img := New(true)
+	img.SetTrack(&Track{Track: 1, Speed: 3, Data: []byte{1, 2, 3}})
+	img.SetTrack(&Track{Track: 36, Speed: 3, Data: []byte{4, 5}})
+	img.SetTrack(&Track{Track: 2, Half: true, Speed: VariableSpeed,
+		SpeedZones: []byte{0xe4}, Data: []byte{6}})
+	data := img.Bytes()
+
+	if string(data[:8]) != Sig1571 {
+		t.Fatalf("unexpected signature: %q", data[:8])
+	}
+	if data[9] != 168 {
+		t.Fatalf("wanted 168 tracks ; got %v", data[9])
+	}
+
+	img2, err := Parse(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if img2.MaxTrackLen != DefaultMaxTrackLen {
+		t.Errorf("wanted max length %v ; got %v", DefaultMaxTrackLen, img2.MaxTrackLen)
+	}
+	t36 := img2.Tracks[84]
+	if t36 == nil || t36.Track != 36 || !bytes.Equal(t36.Data, []byte{4, 5}) {
+		t.Errorf("unexpected track 36: %+v", t36)
+	}
+	half := img2.Tracks[3]
+	if half == nil || half.Track != 2 || !half.Half {
+		t.Fatalf("unexpected half track: %+v", half)
+	}
+	if half.Speed != VariableSpeed || half.SpeedZones[0] != 0xe4 {
+		t.Errorf("unexpected speed zones: %+v", half)
+	}
+	if !bytes.Equal(data, img2.Bytes()) {
+		t.Errorf("images are not the same")
+	}
+}
+
+func TestParseBadSignature(t *testing.T) {
+	data := New(false).Bytes()
+	data[4] = '9'
+	if _, err := Parse(data); err != ErrFormat {
+		t.Fatalf("wanted format error ; got %v", err)
+	}
+}
+
+func TestParseTruncated(t *testing.T) {
+	img := New(false)
+	img.SetTrack(&Track{Track: 1, Data: []byte{1, 2, 3}})
+	data := img.Bytes()
+	if _, err := Parse(data[:len(data)-DefaultMaxTrackLen]); err != ErrFormat {
+		t.Fatalf("wanted format error ; got %v", err)
+	}
+}
+
+func TestSetTrackRange(t *testing.T) {
+	img := New(false)
+	for _, track := range []int{-1, 0, 43} {
+		if err := img.SetTrack(&Track{Track: track}); err != ErrTrack {
+			t.Errorf("%v: wanted %v ; got %v", track, ErrTrack, err)
+		}
+	}
+	if err := img.SetTrack(&Track{Track: 42, Half: true}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := New(true).SetTrack(&Track{Track: 78}); err != ErrTrack {
+		t.Errorf("wanted %v ; got %v", ErrTrack, err)
+	}
+}
+
+func TestBytesOversizeTrack(t *testing.T) {
+	img := New(false)
+	long := bytes.Repeat([]byte{1}, DefaultMaxTrackLen+100)
+	img.SetTrack(&Track{Track: 1, Data: long})
+	img.SetTrack(&Track{Track: 2, Data: bytes.Repeat([]byte{2}, 10)})
+
+	img2, err := Parse(img.Bytes())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if img2.MaxTrackLen != len(long) {
+		t.Errorf("wanted max length %v ; got %v", len(long), img2.MaxTrackLen)
+	}
+	if !bytes.Equal(img2.Track(1).Data, long) {
+		t.Errorf("track 1 is not the same")
+	}
+	if !bytes.Equal(img2.Track(2).Data, img.Track(2).Data) {
+		t.Errorf("track 2 is not the same")
+	}
+	if img.MaxTrackLen != DefaultMaxTrackLen {
+		t.Errorf("wanted image to be unchanged ; got %v", img.MaxTrackLen)
+	}
+}
@@ -0,0 +1,104 @@
+package gcr
+
+// Sector is a block found when decoding a track.
+type Sector struct {
+	Track  int     // Track number found in the header
+	Sector int     // Sector number found in the header
+	ID     [2]byte // Disk ID found in the header
+	Data   []byte  // Contents of the block, nil if not found
+	Err    error   // First problem found when decoding the sector
+}
+
+// TrackReport describes what was found when decoding a track.
+type TrackReport struct {
+	Track   int
+	Half    bool
+	Len     int // Length of the track in bytes
+	Speed   int // Speed zone or VariableSpeed
+	Syncs   int // Number of sync marks found
+	Sectors []*Sector
+}
+
+// Report decodes the sector headers and data blocks found on the track.
+func (t *Track) Report() *TrackReport {
+	r := &TrackReport{
+		Track: t.Track,
+		Half:  t.Half,
+		Len:   len(t.Data),
+		Speed: t.Speed,
+	}
+	if len(t.Data) == 0 {
+		return r
+	}
+	b := bitstream{data: t.Data, n: len(t.Data) * 8}
+	marks := b.syncs()
+	r.Syncs = len(marks)
+
+	// Start with the first header found since the track may begin in
+	// the middle of a sector.
+	start := 0
+	for i, pos := range marks {
+		if id, err := b.decode(pos, 1); err == nil && id[0] == headerID {
+			start = i
+			break
+		}
+	}
+
+	var cur *Sector
+	for i := range marks {
+		pos := marks[(start+i)%len(marks)]
+		id, err := b.decode(pos, 1)
+		if err != nil {
+			continue
+		}
+		switch id[0] {
+		case headerID:
+			h, err := b.decode(pos, headerLen)
+			if err != nil {
+				cur = nil
+				continue
+			}
+			cur = &Sector{
+				Sector: int(h[2]),
+				Track:  int(h[3]),
+				ID:     [2]byte{h[5], h[4]},
+			}
+			if checksum(h[2:6]) != h[1] {
+				cur.Err = ErrHeaderChecksum
+			}
+			r.Sectors = append(r.Sectors, cur)
+		case dataID:
+			if cur == nil || cur.Data != nil {
+				continue
+			}
+			block, err := b.decode(pos, blockLen)
+			if err != nil {
+				if cur.Err == nil {
+					cur.Err = ErrDecode
+				}
+				continue
+			}
+			cur.Data = block[1:257]
+			if checksum(cur.Data) != block[257] && cur.Err == nil {
+				cur.Err = ErrDataChecksum
+			}
+		}
+	}
+	for _, s := range r.Sectors {
+		if s.Data == nil && s.Err == nil {
+			s.Err = ErrNoData
+		}
+	}
+	return r
+}
+
+// Report decodes each track found in the image.
+func (img *Image) Report() []*TrackReport {
+	var list []*TrackReport
+	for _, t := range img.Tracks {
+		if t != nil {
+			list = append(list, t.Report())
+		}
+	}
+	return list
+}
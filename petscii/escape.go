@@ -0,0 +1,158 @@
+package petscii
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MaxRepeat is the largest repeat count allowed in an escape.
+const MaxRepeat = 255
+
+// Names used in escapes for control codes. These match the names used
+// by the petcat tool in VICE.
+var controlNames = map[byte]string{
+	0x03: "stop",
+	0x05: "wht",
+	0x07: "bell",
+	0x08: "dish",
+	0x09: "ensh",
+	0x0d: "return",
+	0x0e: "swlc",
+	0x11: "down",
+	0x12: "rvs on",
+	0x13: "home",
+	0x14: "del",
+	0x1b: "esc",
+	0x1c: "red",
+	0x1d: "right",
+	0x1e: "grn",
+	0x1f: "blu",
+	0x81: "orng",
+	0x85: "f1",
+	0x86: "f3",
+	0x87: "f5",
+	0x88: "f7",
+	0x89: "f2",
+	0x8a: "f4",
+	0x8b: "f6",
+	0x8c: "f8",
+	0x8e: "swuc",
+	0x90: "blk",
+	0x91: "up",
+	0x92: "rvs off",
+	0x93: "clr",
+	0x94: "inst",
+	0x95: "brn",
+	0x96: "lred",
+	0x97: "gry1",
+	0x98: "gry2",
+	0x99: "lgrn",
+	0x9a: "lblu",
+	0x9b: "gry3",
+	0x9c: "pur",
+	0x9d: "left",
+	0x9e: "yel",
+	0x9f: "cyn",
+}
+
+// Other names that are accepted in escapes
+var controlAliases = map[string]byte{
+	"rvon":   0x12,
+	"rvof":   0x92,
+	"rght":   0x1d,
+	"white":  0x05,
+	"black":  0x90,
+	"red":    0x1c,
+	"cyan":   0x9f,
+	"purple": 0x9c,
+	"green":  0x1e,
+	"blue":   0x1f,
+	"yellow": 0x9e,
+	"orange": 0x81,
+	"brown":  0x95,
+	"clear":  0x93,
+}
+
+var controlCodes = make(map[string]byte)
+
+func init() {
+	for code, name := range controlNames {
+		controlCodes[name] = code
+	}
+	for name, code := range controlAliases {
+		controlCodes[name] = code
+	}
+}
+
+// ControlName returns the name used in escapes for a control code.
+func ControlName(b byte) (string, bool) {
+	name, ok := controlNames[b]
+	return name, ok
+}
+
+// ControlCode returns the code for a name used in an escape. Names are
+// not case sensitive.
+func ControlCode(name string) (byte, bool) {
+	b, ok := controlCodes[strings.ToLower(name)]
+	return b, ok
+}
+
+// FormatEscape returns the escape for a code repeated count times. The
+// name of the code is used if it has one, otherwise it is written in
+// hex, such as {$a0}. A count greater than one is written before the
+// name, such as {5 right}.
+func FormatEscape(b byte, count int) string {
+	name, ok := controlNames[b]
+	if !ok {
+		name = fmt.Sprintf("$%02x", b)
+	}
+	if count > 1 {
+		return fmt.Sprintf("{%v %v}", count, name)
+	}
+	return "{" + name + "}"
+}
+
+// ParseEscape reads the escape at the start of the text and returns the
+// codes it stands for and the length of the escape. The escape is
+// either a name or a hex value, such as {$a0}, and may start with a
+// repeat count, such as {5 right}.
+func ParseEscape(text string) ([]byte, int, error) {
+	if text == "" || text[0] != '{' {
+		return nil, 0, fmt.Errorf("escape must start with {")
+	}
+	end := strings.IndexByte(text, '}')
+	if end < 0 {
+		return nil, 0, fmt.Errorf("escape not closed: %v", text)
+	}
+	body := strings.TrimSpace(text[1:end])
+	count := 1
+	if i := strings.IndexByte(body, ' '); i > 0 {
+		if n, err := strconv.Atoi(body[:i]); err == nil {
+			if n < 1 || n > MaxRepeat {
+				return nil, 0, fmt.Errorf("invalid repeat count: %v", text[:end+1])
+			}
+			count = n
+			body = strings.TrimSpace(body[i+1:])
+		}
+	}
+	var b byte
+	if strings.HasPrefix(body, "$") {
+		v, err := strconv.ParseUint(body[1:], 16, 8)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid escape: %v", text[:end+1])
+		}
+		b = byte(v)
+	} else {
+		code, ok := ControlCode(body)
+		if !ok {
+			return nil, 0, fmt.Errorf("unknown escape: %v", text[:end+1])
+		}
+		b = code
+	}
+	codes := make([]byte, count)
+	for i := range codes {
+		codes[i] = b
+	}
+	return codes, end + 1, nil
+}
@@ -0,0 +1,57 @@
+package petscii
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseEscape(t *testing.T) {
+	tests := []struct {
+		text string
+		want []byte
+		n    int
+	}{
+		{"{clr}", []byte{0x93}, 5},
+		{"{CLR}x", []byte{0x93}, 5},
+		{"{rvs on}", []byte{0x12}, 8},
+		{"{rvon}", []byte{0x12}, 6},
+		{"{$a0}", []byte{0xa0}, 5},
+		{"{3 right}", []byte{0x1d, 0x1d, 0x1d}, 9},
+		{"{2 $0d}", []byte{0x0d, 0x0d}, 7},
+	}
+	for _, test := range tests {
+		got, n, err := ParseEscape(test.text)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", test.text, err)
+			continue
+		}
+		if !bytes.Equal(got, test.want) || n != test.n {
+			t.Errorf("%v: wanted %x, %v ; got %x, %v", test.text, test.want, test.n, got, n)
+		}
+	}
+}
+
+func TestParseEscapeErrors(t *testing.T) {
+	tests := []string{"{clr", "{nope}", "{$zz}", "{0 down}", "{256 down}", "{$100}"}
+	for _, test := range tests {
+		if _, _, err := ParseEscape(test); err == nil {
+			t.Errorf("%v: expected error", test)
+		}
+	}
+}
+
+func TestEscapeRoundTrip(t *testing.T) {
+	data := []byte{0x93, 0x11, 0x11, 0x12, 0x48, 0x49, 0x92, 0x1d, 0x1d, 0x1d, 0x00, 0x0d}
+	text := Upper.Decode(data)
+	want := "{clr}{2 down}{rvs on}HI{rvs off}{3 right}{$00}{return}"
+	if text != want {
+		t.Fatalf("wanted %v ; got %v", want, text)
+	}
+	got, err := Upper.Encode(text)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("wanted %x ; got %x", data, got)
+	}
+}
@@ -0,0 +1,236 @@
+// Package petscii converts between PETSCII, screen codes and Unicode.
+//
+// Commodore computers have two character sets and only one can be
+// displayed at a time. The Upper set, used at power on, has uppercase
+// letters and graphics characters. The Lower set has lowercase and
+// uppercase letters and fewer graphics characters. The same PETSCII
+// byte appears as a different character depending on the set in use.
+//
+// Graphics characters are mapped to the Symbols for Legacy Computing
+// block where there is no better match elsewhere in Unicode. Control
+// codes have no printable form and are written in text as escapes
+// compatible with the petcat tool in VICE, such as {clr} or {rvs on}.
+// Codes without a name are written in hex, such as {$a0}.
+package petscii
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Charset is one of the two character sets.
+type Charset int
+
+const (
+	Upper Charset = iota // Uppercase and graphics
+	Lower                // Lowercase and uppercase
+)
+
+func (c Charset) String() string {
+	if c == Lower {
+		return "lower"
+	}
+	return "upper"
+}
+
+// Characters shown for screen codes $00 to $7f in the uppercase and
+// graphics set. Screen codes $80 to $ff are the same characters in
+// reverse video.
+var screenUpper = [128]rune{
+	// $00
+	'@', 'A', 'B', 'C', 'D', 'E', 'F', 'G',
+	'H', 'I', 'J', 'K', 'L', 'M', 'N', 'O',
+	'P', 'Q', 'R', 'S', 'T', 'U', 'V', 'W',
+	'X', 'Y', 'Z', '[', '£', ']', '↑', '←',
+	// $20
+	' ', '!', '"', '#', '$', '%', '&', '\'',
+	'(', ')', '*', '+', ',', '-', '.', '/',
+	'0', '1', '2', '3', '4', '5', '6', '7',
+	'8', '9', ':', ';', '<', '=', '>', '?',
+	// $40
+	'─', '♠', '\U0001fb72', '\U0001fb78',
+	'\U0001fb77', '\U0001fb76', '\U0001fb7a', '\U0001fb71',
+	'\U0001fb74', '╮', '╰', '╯',
+	'\U0001fb7c', '╲', '╱', '\U0001fb7d',
+	'\U0001fb7e', '●', '\U0001fb7b', '♥',
+	'\U0001fb70', '╭', '╳', '○',
+	'♣', '\U0001fb75', '♦', '┼',
+	'\U0001fb8c', '│', 'π', '◥',
+	// $60
+	'\u00a0', '▌', '▄', '▔',
+	'▁', '▏', '▒', '▕',
+	'\U0001fb8f', '◤', '\U0001fb87', '├',
+	'▗', '└', '┐', '▂',
+	'┌', '┴', '┬', '┤',
+	'▎', '▍', '\U0001fb88', '\U0001fb82',
+	'\U0001fb83', '▃', '\U0001fb7f', '▖',
+	'▝', '┘', '▘', '▚',
+}
+
+// Characters in the lowercase and uppercase set that differ from the
+// uppercase and graphics set.
+var screenLowerDiff = map[byte]rune{
+	0x5e: '\U0001fb95',
+	0x5f: '\U0001fb98',
+	0x69: '\U0001fb99',
+	0x7a: '✓',
+}
+
+var screenLower [128]rune
+
+var (
+	fromUnicode = map[Charset]map[rune]byte{
+		Upper: make(map[rune]byte),
+		Lower: make(map[rune]byte),
+	}
+)
+
+func init() {
+	screenLower = screenUpper
+	for i := 0; i < 26; i++ {
+		screenLower[0x01+i] = rune('a' + i)
+		screenLower[0x41+i] = rune('A' + i)
+	}
+	for sc, r := range screenLowerDiff {
+		screenLower[sc] = r
+	}
+	for _, c := range []Charset{Upper, Lower} {
+		table := c.screen()
+		for sc := range table {
+			fromUnicode[c][table[sc]] = FromScreen(byte(sc))
+		}
+	}
+}
+
+func (c Charset) screen() *[128]rune {
+	if c == Lower {
+		return &screenLower
+	}
+	return &screenUpper
+}
+
+// ToScreen returns the screen code for a PETSCII character. Returns
+// false if the character is a control code.
+func ToScreen(b byte) (byte, bool) {
+	switch {
+	case b < 0x20, b >= 0x80 && b < 0xa0:
+		return 0, false
+	case b < 0x40:
+		return b, true
+	case b < 0x60:
+		return b - 0x40, true
+	case b < 0x80:
+		return b - 0x20, true
+	case b < 0xc0:
+		return b - 0x40, true
+	case b < 0xe0:
+		return b - 0x80, true
+	case b == 0xff:
+		return 0x5e, true
+	}
+	return b - 0x80, true
+}
+
+// FromScreen returns the PETSCII character for a screen code. Reverse
+// video, the high bit of the screen code, is ignored. Characters with
+// more than one PETSCII code use the code in the $20-$5f or $a0-$df
+// range.
+func FromScreen(sc byte) byte {
+	sc &= 0x7f
+	switch {
+	case sc < 0x20:
+		return sc + 0x40
+	case sc < 0x40:
+		return sc
+	case sc < 0x60:
+		return sc + 0x80
+	}
+	return sc + 0x40
+}
+
+// Rune returns the character shown for a PETSCII code. Returns false
+// if the code is a control code.
+func (c Charset) Rune(b byte) (rune, bool) {
+	sc, ok := ToScreen(b)
+	if !ok {
+		return 0, false
+	}
+	return c.ScreenRune(sc), true
+}
+
+// ScreenRune returns the character shown for a screen code. Reverse
+// video is ignored.
+func (c Charset) ScreenRune(sc byte) rune {
+	return c.screen()[sc&0x7f]
+}
+
+// Byte returns the PETSCII code for a character. In the Upper set,
+// lowercase letters are converted to uppercase since that set has no
+// lowercase letters. Returns false if the character cannot be found in
+// the set.
+func (c Charset) Byte(r rune) (byte, bool) {
+	if c == Upper && r >= 'a' && r <= 'z' {
+		r -= 'a' - 'A'
+	}
+	b, ok := fromUnicode[c][r]
+	return b, ok
+}
+
+// Decode converts PETSCII to text. Control codes are written as
+// escapes, such as {clr}, and repeated codes use a count, such as
+// {5 right}.
+func (c Charset) Decode(data []byte) string {
+	var b strings.Builder
+	for i := 0; i < len(data); i++ {
+		ch := data[i]
+		if r, ok := c.Rune(ch); ok {
+			b.WriteRune(r)
+			continue
+		}
+		n := 1
+		for i+n < len(data) && data[i+n] == ch && n < MaxRepeat {
+			n++
+		}
+		b.WriteString(FormatEscape(ch, n))
+		i += n - 1
+	}
+	return b.String()
+}
+
+// DecodeString converts a PETSCII string, such as a file name, to text.
+func (c Charset) DecodeString(s string) string {
+	return c.Decode([]byte(s))
+}
+
+// Encode converts text to PETSCII. Escapes, such as {clr} or {$a0}, can
+// be used for any code. Returns an error if a character cannot be found
+// in the set or an escape is invalid.
+func (c Charset) Encode(text string) ([]byte, error) {
+	out := make([]byte, 0, len(text))
+	for i := 0; i < len(text); {
+		if text[i] == '{' {
+			codes, n, err := ParseEscape(text[i:])
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, codes...)
+			i += n
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(text[i:])
+		b, ok := c.Byte(r)
+		if !ok {
+			return nil, fmt.Errorf("character not in %v set: %q", c, r)
+		}
+		out = append(out, b)
+		i += size
+	}
+	return out, nil
+}
+
+// EncodeString converts text to a PETSCII string, such as a file name.
+func (c Charset) EncodeString(text string) (string, error) {
+	data, err := c.Encode(text)
+	return string(data), err
+}
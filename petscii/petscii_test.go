@@ -0,0 +1,94 @@
+package petscii
+
+import (
+	"testing"
+)
+
+func TestScreen(t *testing.T) {
+	tests := []struct {
+		petscii byte
+		screen  byte
+	}{
+		{0x20, 0x20},
+		{0x41, 0x01},
+		{0x5e, 0x1e},
+		{0x61, 0x41},
+		{0xa0, 0x60},
+		{0xc1, 0x41},
+		{0xe9, 0x69},
+		{0xff, 0x5e},
+	}
+	for _, test := range tests {
+		sc, ok := ToScreen(test.petscii)
+		if !ok || sc != test.screen {
+			t.Errorf("%02x: wanted %02x ; got %02x", test.petscii, test.screen, sc)
+		}
+	}
+	for _, b := range []byte{0x00, 0x0d, 0x1f, 0x80, 0x93, 0x9f} {
+		if _, ok := ToScreen(b); ok {
+			t.Errorf("%02x: expected control code", b)
+		}
+	}
+}
+
+// Every screen code maps to a PETSCII code that maps back again and
+// each character is used once in a set.
+func TestRoundTrip(t *testing.T) {
+	for _, c := range []Charset{Upper, Lower} {
+		seen := make(map[rune]byte)
+		for sc := 0; sc < 0x80; sc++ {
+			b := FromScreen(byte(sc))
+			if got, _ := ToScreen(b); got != byte(sc) {
+				t.Errorf("%v: screen %02x: got %02x", c, sc, got)
+			}
+			r := c.ScreenRune(byte(sc))
+			if prev, dup := seen[r]; dup {
+				t.Errorf("%v: %q used by screen codes %02x and %02x", c, r, prev, sc)
+			}
+			seen[r] = byte(sc)
+			if got, ok := c.Byte(r); !ok || got != b {
+				t.Errorf("%v: %q: wanted %02x ; got %02x", c, r, b, got)
+			}
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	data := []byte{0x93, 0x48, 0x49, 0x20, 0xc8, 0x49, 0xd3, 0xa0, 0xff}
+	tests := []struct {
+		c    Charset
+		want string
+	}{
+		{Upper, "{clr}HI \U0001fb74I♥\u00a0π"},
+		{Lower, "{clr}hi HiS\u00a0\U0001fb95"},
+	}
+	for _, test := range tests {
+		if got := test.c.Decode(data); got != test.want {
+			t.Errorf("%v: wanted %q ; got %q", test.c, test.want, got)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		c    Charset
+		text string
+		want string
+	}{
+		{Upper, "hello", "HELLO"},
+		{Upper, "♥{$0d}", "\xd3\x0d"},
+		{Lower, "Hello", "\xc8ELLO"},
+		{Lower, "£←", "\x5c\x5f"},
+	}
+	for _, test := range tests {
+		got, err := test.c.EncodeString(test.text)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.text, err)
+		} else if got != test.want {
+			t.Errorf("%q: wanted %q ; got %q", test.text, test.want, got)
+		}
+	}
+	if _, err := Lower.Encode("♥"); err == nil {
+		t.Errorf("expected error")
+	}
+}
@@ -0,0 +1,68 @@
+package petscii
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TextOptions control how sequential files are converted to and from
+// text.
+type TextOptions struct {
+	Charset   Charset // Character set used to display the data
+	NoEscapes bool    // Remove control codes instead of writing escapes
+}
+
+// ToText converts PETSCII data, with lines ending with a carriage
+// return, to text with lines ending with a line feed.
+func ToText(data []byte, opts TextOptions) string {
+	var b strings.Builder
+	start := 0
+	for i := 0; i <= len(data); i++ {
+		if i < len(data) && data[i] != 0x0d {
+			continue
+		}
+		line := data[start:i]
+		if opts.NoEscapes {
+			line = removeControls(line, opts.Charset)
+		}
+		b.WriteString(opts.Charset.Decode(line))
+		if i < len(data) {
+			b.WriteByte('\n')
+		}
+		start = i + 1
+	}
+	return b.String()
+}
+
+// FromText converts text to PETSCII data. Lines ending with a line feed,
+// or a carriage return and a line feed, end with a carriage return.
+// Escapes are not allowed if NoEscapes is set.
+func FromText(text string, opts TextOptions) ([]byte, error) {
+	lines := strings.Split(text, "\n")
+	var out []byte
+	for i, line := range lines {
+		line = strings.TrimSuffix(line, "\r")
+		if opts.NoEscapes && strings.IndexByte(line, '{') >= 0 {
+			return nil, fmt.Errorf("line %v: escapes are not allowed", i+1)
+		}
+		data, err := opts.Charset.Encode(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %v: %v", i+1, err)
+		}
+		out = append(out, data...)
+		if i < len(lines)-1 {
+			out = append(out, 0x0d)
+		}
+	}
+	return out, nil
+}
+
+func removeControls(data []byte, c Charset) []byte {
+	out := make([]byte, 0, len(data))
+	for _, ch := range data {
+		if _, ok := c.Rune(ch); ok {
+			out = append(out, ch)
+		}
+	}
+	return out
+}
@@ -0,0 +1,56 @@
+package petscii
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTextRoundTrip(t *testing.T) {
+	data := []byte("\x93HELLO\x0d\x0dSCORE,\xc1\x0dEND\x0d")
+	tests := []struct {
+		opts TextOptions
+		want string
+	}{
+		{TextOptions{}, "{clr}HELLO\n\nSCORE,♠\nEND\n"},
+		{TextOptions{Charset: Lower}, "{clr}hello\n\nscore,A\nend\n"},
+	}
+	for _, test := range tests {
+		text := ToText(data, test.opts)
+		if text != test.want {
+			t.Errorf("%v: wanted %q ; got %q", test.opts.Charset, test.want, text)
+			continue
+		}
+		got, err := FromText(text, test.opts)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", test.opts.Charset, err)
+			continue
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("%v: wanted %q ; got %q", test.opts.Charset, data, got)
+		}
+	}
+}
+
+func TestTextNoEscapes(t *testing.T) {
+	opts := TextOptions{NoEscapes: true}
+	if got := ToText([]byte("\x93HI\x0d"), opts); got != "HI\n" {
+		t.Errorf("wanted %q ; got %q", "HI\n", got)
+	}
+	if _, err := FromText("{clr}HI", opts); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestFromTextCRLF(t *testing.T) {
+	got, err := FromText("a\r\nb", TextOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "A\x0dB"; string(got) != want {
+		t.Errorf("wanted %q ; got %q", want, got)
+	}
+	_, err = FromText("ok\n€", TextOptions{})
+	if want := "line 2: character not in upper set: '€'"; err == nil || err.Error() != want {
+		t.Errorf("wanted %v ; got %v", want, err)
+	}
+}
@@ -0,0 +1,310 @@
+package preproc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/blackchip-org/vt128/basic"
+)
+
+// Names that cannot be used for variables since they are keywords or
+// reserved variables.
+var reservedNames = map[string]bool{
+	"GO": true, "IF": true, "ON": true, "OR": true, "TO": true, "FN": true,
+	"DO": true, "ST": true, "TI": true, "DS": true, "ER": true, "EL": true,
+}
+
+// Name is a long variable name and the BASIC name it is replaced with.
+// Array names end with "()" and function names start with "FN ".
+type Name struct {
+	Long  string
+	Short string
+	Pos   basic.Pos // First use in the source
+}
+
+// A variable found in the source
+type varRef struct {
+	name   string    // Name without the type suffix
+	suffix string    // Type suffix, if any
+	kind   string    // Suffix, plus ( for arrays, or FN for functions
+	pos    basic.Pos // Where the name was found
+}
+
+// Names are not case sensitive in BASIC so the key uses the name in
+// uppercase.
+func (v varRef) key() string {
+	return v.kind + " " + strings.ToUpper(v.name)
+}
+
+func (v varRef) format(name string) string {
+	switch {
+	case v.kind == "FN":
+		return "FN " + name
+	case strings.HasSuffix(v.kind, "("):
+		return name + v.suffix + "()"
+	}
+	return name + v.suffix
+}
+
+// Returns true if the name can be used as-is in BASIC. Reserved
+// variables, such as ST, are also left as-is.
+func shortName(name string) bool {
+	return len(name) <= 2 && !strings.ContainsAny(name, "_.")
+}
+
+type mangler struct {
+	p     *processor
+	used  map[string]bool   // Short names in use for each kind
+	names map[string]*Name  // Long names for each kind
+	short map[string]string // Short name without the suffix for each kind
+	order []varRef          // First use of each long name
+	next  map[string]int    // Next candidate to try for each kind
+}
+
+func newMangler(p *processor) *mangler {
+	return &mangler{
+		p:     p,
+		used:  make(map[string]bool),
+		names: make(map[string]*Name),
+		short: make(map[string]string),
+		next:  make(map[string]int),
+	}
+}
+
+// Returns the nth candidate for a short name: single letters first and
+// then a letter followed by a letter or digit.
+func candidate(n int) (string, bool) {
+	const chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	if n < 26 {
+		return chars[n : n+1], true
+	}
+	n -= 26
+	if n >= 26*len(chars) {
+		return "", false
+	}
+	return string([]byte{chars[n/len(chars)], chars[n%len(chars)]}), true
+}
+
+// Record the variables used in the source. Short names are reserved so
+// that long names are not mapped to them.
+func (m *mangler) collect(text string, pos basic.Pos) {
+	m.walk(text, pos, func(v varRef) string {
+		if shortName(v.name) {
+			m.used[v.key()] = true
+		} else if _, ok := m.names[v.key()]; !ok {
+			m.names[v.key()] = &Name{Long: v.format(v.name), Pos: v.pos}
+			m.order = append(m.order, v)
+		}
+		return v.name
+	}, false)
+}
+
+// Assign a short name to each long name in the order they were found.
+func (m *mangler) assign() []*Name {
+	list := make([]*Name, 0, len(m.order))
+	for _, v := range m.order {
+		name := m.names[v.key()]
+		list = append(list, name)
+		for {
+			short, ok := candidate(m.next[v.kind])
+			if !ok {
+				m.p.errorf(v.pos, "too many variables of this type: %v", name.Long)
+				break
+			}
+			m.next[v.kind]++
+			if !reservedNames[short] && !m.used[v.kind+" "+short] {
+				m.used[v.kind+" "+short] = true
+				m.short[v.key()] = short
+				name.Short = v.format(short)
+				break
+			}
+		}
+	}
+	return list
+}
+
+// Replace the long names and labels in the text.
+func (m *mangler) replace(text string, pos basic.Pos) string {
+	return m.walk(text, pos, func(v varRef) string {
+		if short, ok := m.short[v.key()]; ok {
+			return short
+		}
+		return v.name
+	}, true)
+}
+
+// Modes for the names that follow a keyword
+const (
+	modeNone     = iota
+	modeGo       // After GO, waiting for TO
+	modeLabel    // A single label
+	modeList     // A list of labels
+	modeOptional // A label, if defined, or a statement
+	modeFn       // A function name
+)
+
+// Scan the source a word at a time. Unlike the tokenizer, keywords are
+// only found when separated from names. The function is called for
+// each variable name found and returns its replacement. If labels is
+// set, labels are replaced with line numbers and problems are reported.
+func (m *mangler) walk(text string, pos basic.Pos, replace func(varRef) string, labels bool) string {
+	var out strings.Builder
+	mode := modeNone
+	i := 0
+	for i < len(text) {
+		ch := text[i]
+		at := pos
+		at.Col += i
+		switch {
+		case ch == '"':
+			end := strings.IndexByte(text[i+1:], '"')
+			if end < 0 {
+				out.WriteString(text[i:])
+				return out.String()
+			}
+			out.WriteString(text[i : i+end+2])
+			i += end + 2
+			mode = modeNone
+			continue
+		case isDigit(ch) || ch == '.':
+			end := numberEnd(text, i)
+			if labels && (mode == modeLabel || mode == modeList || mode == modeOptional) {
+				m.p.errorf(at, "unexpected line number, use a label instead")
+			}
+			out.WriteString(text[i:end])
+			i = end
+			mode = modeNone
+			continue
+		case !isLetter(ch):
+			out.WriteByte(ch)
+			i++
+			if ch != ' ' && ch != '\t' && !(ch == ',' && mode == modeList) {
+				mode = modeNone
+			}
+			continue
+		}
+
+		end := i + 1
+		for end < len(text) && (isLetter(text[end]) || isDigit(text[end]) || text[end] == '.') {
+			end++
+		}
+		word := text[i:end]
+
+		if kw, kwEnd := keywordAt(text, i, end); kw != "" {
+			out.WriteString(text[i:kwEnd])
+			i = kwEnd
+			next := modeNone
+			switch kw {
+			case "REM":
+				out.WriteString(text[i:])
+				return out.String()
+			case "DATA":
+				end := dataEnd(text, i)
+				out.WriteString(text[i:end])
+				i = end
+			case "GO":
+				next = modeGo
+			case "TO":
+				if mode == modeGo {
+					next = modeList
+				}
+			case "GOTO", "GOSUB":
+				next = modeList
+			case "TRAP", "RESTORE":
+				next = modeLabel
+			case "THEN", "ELSE":
+				next = modeOptional
+			case "FN":
+				next = modeFn
+			}
+			mode = next
+			continue
+		}
+
+		if mode == modeLabel || mode == modeList || mode == modeOptional {
+			num, ok := m.p.labels[word]
+			_, defined := m.p.defined[word]
+			if ok || defined || mode != modeOptional {
+				if !labels {
+					out.WriteString(word)
+				} else if ok {
+					out.WriteString(strconv.Itoa(num))
+				} else {
+					if !defined {
+						m.p.errorf(at, "undefined label: %v", word)
+					}
+					out.WriteString(word)
+				}
+				i = end
+				if mode != modeList {
+					mode = modeNone
+				}
+				continue
+			}
+		}
+
+		v := varRef{name: word, pos: at}
+		if end < len(text) && (text[end] == '$' || text[end] == '%') {
+			v.suffix = text[end : end+1]
+			end++
+		}
+		v.kind = v.suffix
+		if end < len(text) && text[end] == '(' {
+			v.kind += "("
+		}
+		if mode == modeFn {
+			v.kind = "FN"
+		}
+		out.WriteString(replace(v))
+		out.WriteString(v.suffix)
+		i = end
+		mode = modeNone
+	}
+	return out.String()
+}
+
+// Returns the keyword for the word found from i to end and the index
+// after the keyword. Keywords that end with $, ( or # include that
+// character. Returns an empty string if the word is not a keyword.
+func keywordAt(text string, i int, end int) (string, int) {
+	if end < len(text) && strings.IndexByte("$(#", text[end]) >= 0 {
+		if kw, ok := basic.Keyword(text[i : end+1]); ok && len(kw) == end+1-i {
+			return kw, end + 1
+		}
+	}
+	if kw, ok := basic.Keyword(text[i:end]); ok && len(kw) == end-i {
+		return kw, end
+	}
+	return "", i
+}
+
+// Returns the index after the number that starts at i
+func numberEnd(text string, i int) int {
+	for i < len(text) && (isDigit(text[i]) || text[i] == '.') {
+		i++
+	}
+	if i < len(text) && (text[i] == 'e' || text[i] == 'E') {
+		j := i + 1
+		if j < len(text) && (text[j] == '+' || text[j] == '-') {
+			j++
+		}
+		if j < len(text) && isDigit(text[j]) {
+			i = j
+			for i < len(text) && isDigit(text[i]) {
+				i++
+			}
+		}
+	}
+	return i
+}
+
+// NameTable returns the long names and the BASIC names they were
+// replaced with, one per line.
+func (r *Result) NameTable() string {
+	var b strings.Builder
+	for _, name := range r.Names {
+		fmt.Fprintf(&b, "%-8v %-24v %v\n", name.Short, name.Long, name.Pos)
+	}
+	return b.String()
+}
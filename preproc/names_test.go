@@ -0,0 +1,111 @@
+package preproc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMangle(t *testing.T) {
+	src := `
+    dim names$(10)
+    a = 1 : b = 2
+    playerScore = 0 : totalScore = playerScore + a
+    names$(1) = "bob" : name$ = names$(1)
+    enemy.x% = 5 : onTime = ti
+    def fn square(x) = x * x : print fn square(3)
+    for index = 1 to 10 : next index
+again:
+    if onTime then again
+    print chr$(65); "playerScore"
+    rem playerScore
+    data playerScore
+`
+	want := `10 dim A$(10)
+20 a = 1 : b = 2
+30 C = 0 : D = C + a
+40 A$(1) = "bob" : A$ = A$(1)
+50 A% = 5 : E = ti
+60 def fn A(x) = x * x : print fn A(3)
+70 for F = 1 to 10 : next F
+80 if E then 80
+90 print chr$(65); "playerScore"
+100 rem playerScore
+110 data playerScore
+`
+	res, err := Process(strings.NewReader(src), "test.bas", Options{Mangle: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := res.Text(); got != want {
+		t.Fatalf("\nwanted:\n%v\ngot:\n%v", want, got)
+	}
+
+	table := []struct{ long, short string }{
+		{"names$()", "A$()"},
+		{"playerScore", "C"},
+		{"totalScore", "D"},
+		{"name$", "A$"},
+		{"enemy.x%", "A%"},
+		{"onTime", "E"},
+		{"FN square", "FN A"},
+		{"index", "F"},
+	}
+	if len(res.Names) != len(table) {
+		t.Fatalf("wanted %v names ; got:\n%v", len(table), res.NameTable())
+	}
+	for i, want := range table {
+		got := res.Names[i]
+		if got.Long != want.long || got.Short != want.short {
+			t.Errorf("wanted %v=%v ; got %v=%v", want.long, want.short, got.Long, got.Short)
+		}
+	}
+	if pos := res.Names[1].Pos; pos.Line != 4 || pos.Col != 5 {
+		t.Errorf("unexpected position: %v", pos)
+	}
+}
+
+func TestMangleReserved(t *testing.T) {
+	// Skip past the single letters to reach the reserved names
+	var b strings.Builder
+	for i := 0; i < 26+36*4+20; i++ {
+		b.WriteString("print value")
+		b.WriteString(strings.Repeat("x", i))
+		b.WriteString("\n")
+	}
+	res, err := Process(strings.NewReader(b.String()), "", Options{Mangle: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, name := range res.Names {
+		if reservedNames[name.Short] {
+			t.Errorf("reserved name used: %v", name.Short)
+		}
+	}
+	if got := res.Names[26].Short; got != "AA" {
+		t.Errorf("wanted AA ; got %v", got)
+	}
+}
+
+func TestMangleErrors(t *testing.T) {
+	src := "goto nowhere\nprint 1 : goto 10\n"
+	want := "test.bas:1:6: undefined label: nowhere\ntest.bas:2:16: unexpected line number, use a label instead"
+	_, err := Process(strings.NewReader(src), "test.bas", Options{Mangle: true})
+	if err == nil || err.Error() != want {
+		t.Fatalf("wanted:\n%v\ngot:\n%v", want, err)
+	}
+}
+
+func TestMangleCase(t *testing.T) {
+	src := "playerScore = 1 : PlayerScore = 2 : print PLAYERSCORE\n"
+	want := "10 A = 1 : A = 2 : print A\n"
+	res, err := Process(strings.NewReader(src), "test.bas", Options{Mangle: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := res.Text(); got != want {
+		t.Fatalf("\nwanted:\n%v\ngot:\n%v", want, got)
+	}
+	if len(res.Names) != 1 || res.Names[0].Long != "playerScore" {
+		t.Errorf("unexpected names:\n%v", res.NameTable())
+	}
+}
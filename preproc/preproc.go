@@ -0,0 +1,358 @@
+// Package preproc converts BASIC source that uses labels instead of
+// line numbers into numbered BASIC 7.0 text.
+//
+// A label is defined by starting a line with a name followed by a colon.
+// The name is made up of letters, digits and underscores and must not
+// start with a digit. A keyword, such as LOOP, cannot be used as a name.
+// The label refers to the line that follows it or to the statements on
+// the same line if there are any:
+//
+//	again:
+//	    print "hello"
+//	    goto again
+//
+// Labels can be used after GOTO, GO TO, GOSUB, TRAP, RESTORE, and in
+// the lists used with ON. A label can also follow THEN or ELSE. Since a
+// statement can also follow THEN or ELSE, the name is only replaced
+// there if a label with that name exists. Label names are case
+// sensitive.
+//
+// When the Mangle option is set, variables can have long names made up
+// of letters, digits, underscores and periods. Type suffixes and arrays
+// work as they do in BASIC: score, score%, score$ and score() are four
+// different variables. Each long name is replaced with a short name
+// that is not used elsewhere in the program, is not a keyword, and is
+// not a reserved variable such as ST or TI. Names with one or two
+// characters are left as-is. In this mode, keywords are only found when
+// they are separated from names by a space or another character so
+// that names like totalScore or onTime can be used. The mapping is
+// found in the Names of the Result.
+package preproc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/blackchip-org/vt128/basic"
+)
+
+const (
+	DefaultStart = 10
+	DefaultStep  = 10
+)
+
+// Options control how line numbers are assigned. Zero values use the
+// defaults.
+type Options struct {
+	Start  int  // Number of the first line
+	Step   int  // Increment between line numbers
+	Mangle bool // Replace long variable names with short ones
+}
+
+// Line is a numbered line of output.
+type Line struct {
+	Num  int
+	Text string    // Contents of the line without the line number
+	Pos  basic.Pos // Where the contents are found in the source
+}
+
+// Result is the numbered program, the line number assigned to each
+// label, and the long variable names that were replaced.
+type Result struct {
+	Lines  []*Line
+	Labels map[string]int
+	Names  []*Name
+}
+
+// ErrorList is returned when problems are found in the source.
+type ErrorList []*basic.Error
+
+func (e ErrorList) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+type label struct {
+	name string
+	pos  basic.Pos
+}
+
+type processor struct {
+	labels  map[string]int
+	defined map[string]basic.Pos
+	errs    ErrorList
+}
+
+func (p *processor) errorf(pos basic.Pos, format string, args ...interface{}) {
+	p.errs = append(p.errs, &basic.Error{Pos: pos, Msg: fmt.Sprintf(format, args...)})
+}
+
+// Process reads source text with labels and assigns line numbers. All
+// problems found are returned as an ErrorList.
+func Process(r io.Reader, filename string, opts Options) (*Result, error) {
+	if opts.Start <= 0 {
+		opts.Start = DefaultStart
+	}
+	if opts.Step <= 0 {
+		opts.Step = DefaultStep
+	}
+	p := &processor{
+		labels:  make(map[string]int),
+		defined: make(map[string]basic.Pos),
+	}
+	res := &Result{Labels: p.labels}
+
+	// Assign line numbers and find where the labels are defined
+	var pending []label
+	num := opts.Start
+	s := bufio.NewScanner(r)
+	lineNo := 0
+	for s.Scan() {
+		lineNo++
+		pos := basic.Pos{File: filename, Line: lineNo, Col: 1}
+		text, pos := trimLeft(strings.TrimRight(s.Text(), " \t\r"), pos)
+		if text == "" {
+			continue
+		}
+		if name, rest, ok := labelDef(text); ok {
+			if prev, dup := p.defined[name]; dup {
+				p.errorf(pos, "duplicate label: %v (first defined at %v)", name, prev)
+			} else {
+				p.defined[name] = pos
+				pending = append(pending, label{name: name, pos: pos})
+			}
+			pos.Col += len(name) + 1
+			text, pos = trimLeft(rest, pos)
+			if text == "" {
+				continue
+			}
+		}
+		if text[0] >= '0' && text[0] <= '9' {
+			p.errorf(pos, "unexpected line number, use a label instead")
+		}
+		if num > basic.MaxLineNum {
+			p.errorf(pos, "line number too large: %v", num)
+			break
+		}
+		for _, l := range pending {
+			p.labels[l.name] = num
+		}
+		pending = nil
+		res.Lines = append(res.Lines, &Line{Num: num, Text: text, Pos: pos})
+		num += opts.Step
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	for _, l := range pending {
+		p.errorf(l.pos, "label %v is not followed by a line", l.name)
+	}
+
+	// Replace label references with line numbers
+	if opts.Mangle {
+		m := newMangler(p)
+		for _, line := range res.Lines {
+			m.collect(line.Text, line.Pos)
+		}
+		res.Names = m.assign()
+		for _, line := range res.Lines {
+			line.Text = m.replace(line.Text, line.Pos)
+		}
+	} else {
+		for _, line := range res.Lines {
+			line.Text = p.replace(line.Text, line.Pos)
+		}
+	}
+	if len(p.errs) > 0 {
+		return nil, p.errs
+	}
+	return res, nil
+}
+
+// Text returns the numbered program.
+func (r *Result) Text() string {
+	var b strings.Builder
+	for _, line := range r.Lines {
+		fmt.Fprintf(&b, "%v %v\n", line.Num, line.Text)
+	}
+	return b.String()
+}
+
+// Program tokenizes the numbered lines. The position of each line in
+// the program, and of any errors found, refer to the source.
+func (r *Result) Program() (*basic.Program, error) {
+	p := basic.NewProgram()
+	for _, line := range r.Lines {
+		prefix := strconv.Itoa(line.Num) + " "
+		pos := line.Pos
+		pos.Col -= len(prefix)
+		tl, err := basic.TokenizeLine(prefix+line.Text, pos)
+		if err != nil {
+			return nil, err
+		}
+		tl.Pos = line.Pos
+		p.Lines = append(p.Lines, tl)
+	}
+	return p, nil
+}
+
+func trimLeft(text string, pos basic.Pos) (string, basic.Pos) {
+	trimmed := strings.TrimLeft(text, " \t")
+	pos.Col += len(text) - len(trimmed)
+	return trimmed, pos
+}
+
+func isLetter(ch byte) bool {
+	return ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch == '_'
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+// Returns the length of the label name at the start of the text
+func nameLen(text string) int {
+	if text == "" || !isLetter(text[0]) {
+		return 0
+	}
+	n := 1
+	for n < len(text) && (isLetter(text[n]) || isDigit(text[n])) {
+		n++
+	}
+	return n
+}
+
+// Returns the name of the label if the text starts with a definition
+// and the text that follows it. A keyword followed by a colon, such as
+// END, is a statement and not a label.
+func labelDef(text string) (string, string, bool) {
+	n := nameLen(text)
+	if n == 0 || n >= len(text) || text[n] != ':' {
+		return "", "", false
+	}
+	name := text[:n]
+	if kw, ok := basic.Keyword(name); ok && len(kw) == n {
+		return "", "", false
+	}
+	return name, text[n+1:], true
+}
+
+// Scan the text in the same way it would be tokenized and replace the
+// labels found after the keywords that use line numbers.
+func (p *processor) replace(text string, pos basic.Pos) string {
+	var out strings.Builder
+	i := 0
+	for i < len(text) {
+		ch := text[i]
+		if ch == '"' {
+			end := strings.IndexByte(text[i+1:], '"')
+			if end < 0 {
+				break
+			}
+			out.WriteString(text[i : i+end+2])
+			i += end + 2
+			continue
+		}
+		kw, ok := "", false
+		if !isDigit(ch) {
+			kw, ok = basic.Keyword(text[i:])
+		}
+		if !ok {
+			out.WriteByte(ch)
+			i++
+			continue
+		}
+		out.WriteString(text[i : i+len(kw)])
+		i += len(kw)
+		switch kw {
+		case "REM":
+			out.WriteString(text[i:])
+			return out.String()
+		case "DATA":
+			end := dataEnd(text, i)
+			out.WriteString(text[i:end])
+			i = end
+		case "GO":
+			// GO TO with a space in between
+			next, _ := trimLeft(text[i:], pos)
+			if to, ok := basic.Keyword(next); ok && to == "TO" {
+				start := len(text) - len(next)
+				out.WriteString(text[i : start+2])
+				i = p.refs(&out, text, start+2, pos, true, false)
+			}
+		case "GOTO", "GOSUB":
+			i = p.refs(&out, text, i, pos, true, false)
+		case "TRAP", "RESTORE":
+			i = p.refs(&out, text, i, pos, false, false)
+		case "THEN", "ELSE":
+			i = p.refs(&out, text, i, pos, false, true)
+		}
+	}
+	out.WriteString(text[i:])
+	return out.String()
+}
+
+// Returns the index of the colon that ends a DATA statement or the end
+// of the text.
+func dataEnd(text string, i int) int {
+	quote := false
+	for ; i < len(text); i++ {
+		switch text[i] {
+		case '"':
+			quote = !quote
+		case ':':
+			if !quote {
+				return i
+			}
+		}
+	}
+	return i
+}
+
+// Replace the label, or comma separated labels if list is set, found at
+// index i. If optional is set, a name that is not a label is left
+// as-is. Returns the index after the last label replaced.
+func (p *processor) refs(out *strings.Builder, text string, i int, pos basic.Pos, list bool, optional bool) int {
+	for {
+		rest, _ := trimLeft(text[i:], pos)
+		start := len(text) - len(rest)
+		at := pos
+		at.Col += start
+		n := nameLen(rest)
+		if n == 0 {
+			if rest != "" && isDigit(rest[0]) {
+				p.errorf(at, "unexpected line number, use a label instead")
+			}
+			return i
+		}
+		name := rest[:n]
+		num, ok := p.labels[name]
+		if !ok {
+			if optional {
+				return i
+			}
+			if _, defined := p.defined[name]; !defined {
+				p.errorf(at, "undefined label: %v", name)
+			}
+		}
+		out.WriteString(text[i:start])
+		out.WriteString(strconv.Itoa(num))
+		i = start + n
+		if !list {
+			return i
+		}
+		rest, _ = trimLeft(text[i:], pos)
+		if rest == "" || rest[0] != ',' {
+			return i
+		}
+		comma := len(text) - len(rest)
+		out.WriteString(text[i : comma+1])
+		i = comma + 1
+	}
+}
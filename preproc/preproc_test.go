@@ -0,0 +1,122 @@
+package preproc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestProcess(t *testing.T) {
+	src := `
+start:
+    print "hello"
+    gosub sub : goto done
+again: x = x + 1 : if x < 10 then again else done
+    on x goto start, again ,done
+    go to start
+    trap done
+    restore values
+    rem goto nowhere
+    print "goto nowhere" : if x then print
+values:
+    data goto,nowhere: goto start
+end:
+done: end
+sub: return
+`
+	want := `10 print "hello"
+20 gosub 130 : goto 120
+30 x = x + 1 : if x < 10 then 30 else 120
+40 on x goto 10, 30 ,120
+50 go to 10
+60 trap 120
+70 restore 100
+80 rem goto nowhere
+90 print "goto nowhere" : if x then print
+100 data goto,nowhere: goto 10
+110 end:
+120 end
+130 return
+`
+	res, err := Process(strings.NewReader(src), "test.bas", Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := res.Text(); got != want {
+		t.Fatalf("\nwanted:\n%v\ngot:\n%v", want, got)
+	}
+	if res.Labels["values"] != 100 {
+		t.Errorf("wanted values at 100 ; got %v", res.Labels["values"])
+	}
+	if pos := res.Lines[2].Pos; pos.Line != 5 || pos.Col != 8 {
+		t.Errorf("unexpected position: %v", pos)
+	}
+}
+
+func TestProcessOptions(t *testing.T) {
+	src := "top: print\ngoto top\n"
+	res, err := Process(strings.NewReader(src), "", Options{Start: 1000, Step: 5})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "1000 print\n1005 goto 1000\n"
+	if got := res.Text(); got != want {
+		t.Fatalf("wanted %q ; got %q", want, got)
+	}
+}
+
+func TestProcessErrors(t *testing.T) {
+	src := `
+top:
+    goto missing
+top: print
+    10 print
+    gosub top, nope
+last:
+`
+	want := []string{
+		"test.bas:4:1: duplicate label: top (first defined at test.bas:2:1)",
+		"test.bas:5:5: unexpected line number, use a label instead",
+		"test.bas:7:1: label last is not followed by a line",
+		"test.bas:3:10: undefined label: missing",
+		"test.bas:6:16: undefined label: nope",
+	}
+	_, err := Process(strings.NewReader(src), "test.bas", Options{})
+	list, ok := err.(ErrorList)
+	if !ok {
+		t.Fatalf("wanted error list ; got %v", err)
+	}
+	if len(list) != len(want) {
+		t.Fatalf("wanted %v errors ; got:\n%v", len(want), err)
+	}
+	for i := range want {
+		if list[i].Error() != want[i] {
+			t.Errorf("wanted %v ; got %v", want[i], list[i])
+		}
+	}
+}
+
+func TestProgram(t *testing.T) {
+	src := "top:\n  print \"a\"\n  goto top\n  print \"€\"\n"
+	res, err := Process(strings.NewReader(src), "test.bas", Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err = res.Program()
+	want := "test.bas:4:10: unsupported character: '€'"
+	if err == nil || err.Error() != want {
+		t.Fatalf("wanted %v ; got %v", want, err)
+	}
+
+	res, _ = Process(strings.NewReader("top:\n  goto top\n"), "test.bas", Options{})
+	p, err := res.Program()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	line := p.Lines[0]
+	if line.Num != 10 || string(line.Tokens) != "\x89 10" {
+		t.Errorf("unexpected line: %+v", line)
+	}
+	if line.Pos.Line != 2 || line.Pos.Col != 3 {
+		t.Errorf("unexpected position: %v", line.Pos)
+	}
+}